@@ -0,0 +1,315 @@
+package gripmock
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/gripmock/stuber"
+
+	"github.com/Dmytro-Hladkykh/gripmock/internal/proto"
+)
+
+// adminServer implements the generated admin REST API on top of a Server
+type adminServer struct {
+	server *Server
+}
+
+var _ proto.ServerInterface = (*adminServer)(nil)
+
+func newAdminHandler(server *Server) http.Handler {
+	return proto.Handler(&adminServer{server: server})
+}
+
+func (a *adminServer) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, proto.MessageOK{Message: "ok", Time: time.Now()})
+}
+
+func (a *adminServer) Readiness(w http.ResponseWriter, r *http.Request) {
+	if !a.server.IsRunning() {
+		writeJSON(w, http.StatusServiceUnavailable, proto.MessageOK{Message: "not ready", Time: time.Now()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, proto.MessageOK{Message: "ok", Time: time.Now()})
+}
+
+func (a *adminServer) ServicesList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.server.Services())
+}
+
+func (a *adminServer) ServiceMethodsList(w http.ResponseWriter, r *http.Request, serviceID string) {
+	for _, service := range a.server.Services() {
+		if service.Id == serviceID {
+			writeJSON(w, http.StatusOK, service.Methods)
+			return
+		}
+	}
+
+	http.Error(w, "service not found: "+serviceID, http.StatusNotFound)
+}
+
+func (a *adminServer) PurgeStubs(w http.ResponseWriter, r *http.Request) {
+	a.server.ClearStubs()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminServer) ListStubs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.toAPIStubs(a.server.budgerigar.All()))
+}
+
+func (a *adminServer) AddStub(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The body is either a single stub or a list of stubs
+	var apiStubs []proto.Stub
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(body, &apiStubs)
+	} else {
+		var apiStub proto.Stub
+		err = json.Unmarshal(body, &apiStub)
+		apiStubs = append(apiStubs, apiStub)
+	}
+	if err != nil {
+		http.Error(w, "invalid stub: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ids := make(proto.ListID, 0, len(apiStubs))
+	for _, apiStub := range apiStubs {
+		stub, ext := fromAPIStub(apiStub)
+		if err := a.server.AddStubWithExtension(stub, ext); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ids = append(ids, stub.ID)
+	}
+
+	writeJSON(w, http.StatusOK, ids)
+}
+
+func (a *adminServer) BatchStubsDelete(w http.ResponseWriter, r *http.Request) {
+	var ids proto.ListID
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		http.Error(w, "invalid list of ids: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.server.DeleteStubs(ids...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminServer) SearchStubs(w http.ResponseWriter, r *http.Request) {
+	var search proto.SearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+		http.Error(w, "invalid search request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, _ := search.Data.(map[string]interface{})
+	headers := make(map[string]interface{}, len(search.Headers))
+	for k, v := range search.Headers {
+		headers[k] = v
+	}
+
+	result, err := a.server.budgerigar.FindByQuery(stuber.Query{
+		ID:      search.Id,
+		Service: search.Service,
+		Method:  search.Method,
+		Headers: headers,
+		Data:    data,
+	})
+	if err != nil || result.Found() == nil {
+		http.Error(w, "no stub found for service "+search.Service+", method "+search.Method, http.StatusNotFound)
+		return
+	}
+
+	found := result.Found()
+	writeJSON(w, http.StatusOK, proto.SearchResponse{
+		Code:    found.Output.Code,
+		Data:    found.Output.Data,
+		Error:   found.Output.Error,
+		Headers: found.Output.Headers,
+	})
+}
+
+func (a *adminServer) ListUnusedStubs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.toAPIStubs(a.server.UnusedStubs()))
+}
+
+func (a *adminServer) ListUsedStubs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.toAPIStubs(a.server.UsedStubs()))
+}
+
+func (a *adminServer) DeleteStubByID(w http.ResponseWriter, r *http.Request, id proto.ID) {
+	if a.server.DeleteStubs(id) == 0 {
+		http.Error(w, "stub not found: "+id.String(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminServer) FindByID(w http.ResponseWriter, r *http.Request, id proto.ID) {
+	stub := a.server.budgerigar.FindByID(id)
+	if stub == nil {
+		http.Error(w, "stub not found: "+id.String(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, a.toAPIStub(stub))
+}
+
+func (a *adminServer) toAPIStubs(stubs []*stuber.Stub) proto.StubList {
+	apiStubs := make(proto.StubList, 0, len(stubs))
+	for _, stub := range stubs {
+		apiStubs = append(apiStubs, a.toAPIStub(stub))
+	}
+
+	return apiStubs
+}
+
+// toAPIStub converts a stub and its extension into the REST API model
+func (a *adminServer) toAPIStub(stub *stuber.Stub) proto.Stub {
+	ext := a.server.extensions.get(stub.ID)
+	id := stub.ID
+	priority := stub.Priority
+
+	apiStub := proto.Stub{
+		Id:       &id,
+		Service:  stub.Service,
+		Method:   stub.Method,
+		Priority: &priority,
+		Headers: proto.StubHeaders{
+			Equals:   toStringMap(stub.Headers.Equals),
+			Contains: toStringMap(stub.Headers.Contains),
+			Matches:  toStringMap(stub.Headers.Matches),
+		},
+		Input: toAPIInput(stub.Input),
+		Output: proto.StubOutput{
+			Code:    stub.Output.Code,
+			Data:    stub.Output.Data,
+			Error:   stub.Output.Error,
+			Headers: stub.Output.Headers,
+			Stream:  ext.Stream,
+		},
+	}
+
+	if len(ext.Inputs) > 0 {
+		inputs := make([]proto.StubInput, 0, len(ext.Inputs))
+		for _, input := range ext.Inputs {
+			inputs = append(inputs, toAPIInput(input))
+		}
+		apiStub.Inputs = &inputs
+	}
+
+	return apiStub
+}
+
+// fromAPIStub converts a stub of the REST API model into a stub and its extension
+func fromAPIStub(apiStub proto.Stub) (*stuber.Stub, *StubExtension) {
+	stub := &stuber.Stub{
+		Service: apiStub.Service,
+		Method:  apiStub.Method,
+		Headers: stuber.InputHeader{
+			Equals:   fromStringMap(apiStub.Headers.Equals),
+			Contains: fromStringMap(apiStub.Headers.Contains),
+			Matches:  fromStringMap(apiStub.Headers.Matches),
+		},
+		Input: fromAPIInput(apiStub.Input),
+		Output: stuber.Output{
+			Code:    apiStub.Output.Code,
+			Data:    apiStub.Output.Data,
+			Error:   apiStub.Output.Error,
+			Headers: apiStub.Output.Headers,
+		},
+	}
+
+	if apiStub.Id != nil {
+		stub.ID = *apiStub.Id
+	} else {
+		stub.ID = uuid.New()
+	}
+
+	if apiStub.Priority != nil {
+		stub.Priority = *apiStub.Priority
+	}
+
+	ext := &StubExtension{Stream: apiStub.Output.Stream}
+	if apiStub.Inputs != nil {
+		for _, input := range *apiStub.Inputs {
+			ext.Inputs = append(ext.Inputs, fromAPIInput(input))
+		}
+	}
+
+	return stub, ext
+}
+
+func toAPIInput(input stuber.InputData) proto.StubInput {
+	apiInput := proto.StubInput{
+		Equals:   input.Equals,
+		Contains: input.Contains,
+		Matches:  input.Matches,
+	}
+
+	if input.IgnoreArrayOrder {
+		ignoreArrayOrder := true
+		apiInput.IgnoreArrayOrder = &ignoreArrayOrder
+	}
+
+	return apiInput
+}
+
+func fromAPIInput(apiInput proto.StubInput) stuber.InputData {
+	input := stuber.InputData{
+		Equals:   apiInput.Equals,
+		Contains: apiInput.Contains,
+		Matches:  apiInput.Matches,
+	}
+
+	if apiInput.IgnoreArrayOrder != nil {
+		input.IgnoreArrayOrder = *apiInput.IgnoreArrayOrder
+	}
+
+	return input
+}
+
+func toStringMap(values map[string]interface{}) map[string]string {
+	if values == nil {
+		return nil
+	}
+
+	result := make(map[string]string, len(values))
+	for k, v := range values {
+		result[k] = fmt.Sprint(v)
+	}
+
+	return result
+}
+
+func fromStringMap(values map[string]string) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+
+	result := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		result[k] = v
+	}
+
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
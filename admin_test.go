@@ -0,0 +1,49 @@
+package gripmock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestEphemeralAdminPort(t *testing.T) {
+	server, _ := startServer(t, []string{"testdata/demo"}, WithAdminPort(0))
+
+	port := server.GetAdminPort()
+	if port == 0 {
+		t.Fatal("GetAdminPort = 0, want the assigned port")
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/health/liveness", port))
+	if err != nil {
+		t.Fatalf("GET liveness: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("liveness status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestAdminPortDisabled(t *testing.T) {
+	server, _ := startServer(t, []string{"testdata/demo"})
+
+	if port := server.GetAdminPort(); port != 0 {
+		t.Fatalf("GetAdminPort = %d, want 0", port)
+	}
+}
+
+func TestAdminServerQuickRestart(t *testing.T) {
+	server, err := NewServer(0, []string{"testdata/demo"}, WithInMemory(), WithAdminPort(0))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	for range 20 {
+		if err := server.Start(context.Background()); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		server.Stop()
+	}
+}
@@ -0,0 +1,248 @@
+package gripmock
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gripmock/stuber"
+	"google.golang.org/grpc/codes"
+)
+
+// StubBuilder builds a stub step by step, e.g.
+//
+//	err := mocker.On("helloworld.Greeter/SayHello").
+//		WithHeader("authorization", "Bearer token").
+//		Matching(map[string]interface{}{"name": "gripmock"}).
+//		Returns(map[string]interface{}{"message": "Hello"}).
+//		Times(2).
+//		Delay(50 * time.Millisecond).
+//		Add()
+type StubBuilder struct {
+	stub *stuber.Stub
+	ext  *StubExtension
+	err  error
+	add  func(*stuber.Stub, *StubExtension) error
+}
+
+// On starts a stub for a method such as "pkg.Service/Method" on all servers
+func On(method string) *StubBuilder {
+	return newStubBuilder(method, func(stub *stuber.Stub, ext *StubExtension) error {
+		if globalManager == nil {
+			return fmt.Errorf("embedded gripmock not initialized - call InitEmbeddedGripmock first")
+		}
+		return globalManager.addStubWithExtension(stub, ext)
+	})
+}
+
+// On starts a stub for a method such as "pkg.Service/Method" on this server
+func (m *EmbeddedMocker) On(method string) *StubBuilder {
+	return m.server.On(method)
+}
+
+// On starts a stub for a method such as "pkg.Service/Method" on this server
+func (s *Server) On(method string) *StubBuilder {
+	return newStubBuilder(method, s.AddStubWithExtension)
+}
+
+func newStubBuilder(fullMethod string, add func(*stuber.Stub, *StubExtension) error) *StubBuilder {
+	service, method, err := splitMethod(fullMethod)
+
+	return &StubBuilder{
+		stub: &stuber.Stub{
+			Service: service,
+			Method:  method,
+			Input:   stuber.InputData{Matches: map[string]interface{}{}},
+		},
+		ext: &StubExtension{},
+		err: err,
+		add: add,
+	}
+}
+
+// WithHeader matches requests whose header equals the value
+func (b *StubBuilder) WithHeader(key, value string) *StubBuilder {
+	b.stub.Headers.Equals = setValue(b.stub.Headers.Equals, key, value)
+	return b
+}
+
+// WithHeaderContaining matches requests whose header contains the value
+func (b *StubBuilder) WithHeaderContaining(key, value string) *StubBuilder {
+	b.stub.Headers.Contains = setValue(b.stub.Headers.Contains, key, value)
+	return b
+}
+
+// WithHeaderMatching matches requests whose header matches the regular expression
+func (b *StubBuilder) WithHeaderMatching(key, pattern string) *StubBuilder {
+	b.stub.Headers.Matches = setValue(b.stub.Headers.Matches, key, pattern)
+	return b
+}
+
+// Matching matches requests equal to the data
+func (b *StubBuilder) Matching(data map[string]interface{}) *StubBuilder {
+	b.stub.Input.Equals = mergeValues(b.stub.Input.Equals, data)
+	return b
+}
+
+// Containing matches requests that contain the data
+func (b *StubBuilder) Containing(data map[string]interface{}) *StubBuilder {
+	b.stub.Input.Contains = mergeValues(b.stub.Input.Contains, data)
+	return b
+}
+
+// MatchingPatterns matches requests whose fields match the regular expressions
+func (b *StubBuilder) MatchingPatterns(patterns map[string]interface{}) *StubBuilder {
+	b.stub.Input.Matches = mergeValues(b.stub.Input.Matches, patterns)
+	return b
+}
+
+// IgnoringArrayOrder compares repeated fields regardless of their order
+func (b *StubBuilder) IgnoringArrayOrder() *StubBuilder {
+	b.stub.Input.IgnoreArrayOrder = true
+	return b
+}
+
+// MatchingAny matches requests satisfying any of the inputs, replacing the input
+// rules above, for unary, server-streaming and bidirectional methods
+func (b *StubBuilder) MatchingAny(inputs ...stuber.InputData) *StubBuilder {
+	b.ext.Inputs = append(b.ext.Inputs, inputs...)
+	return b
+}
+
+// MatchingSequence matches client-streaming calls whose messages satisfy the
+// inputs one by one, in order, replacing the input rules above
+func (b *StubBuilder) MatchingSequence(inputs ...stuber.InputData) *StubBuilder {
+	b.ext.Inputs = append(b.ext.Inputs, inputs...)
+	return b
+}
+
+// Priority ranks the stub above stubs of lower priority that also match
+func (b *StubBuilder) Priority(priority int) *StubBuilder {
+	b.stub.Priority = priority
+	return b
+}
+
+// Returns answers with the data
+func (b *StubBuilder) Returns(data map[string]interface{}) *StubBuilder {
+	b.stub.Output.Data = data
+	return b
+}
+
+// ReturnsStream answers server-streaming calls with the messages, in order
+func (b *StubBuilder) ReturnsStream(messages ...map[string]interface{}) *StubBuilder {
+	b.ext.Stream = append(b.ext.Stream, messages...)
+	return b
+}
+
+// ReturnsError answers with a status error
+func (b *StubBuilder) ReturnsError(code codes.Code, message string) *StubBuilder {
+	b.stub.Output.Code = &code
+	b.stub.Output.Error = message
+	return b
+}
+
+// ReturnsCode answers with the status code; codes.OK sends the data
+func (b *StubBuilder) ReturnsCode(code codes.Code) *StubBuilder {
+	b.stub.Output.Code = &code
+	return b
+}
+
+// WithErrorDetails adds typed details to the status error, each naming its
+// type in "@type", e.g. google.rpc.ErrorInfo
+func (b *StubBuilder) WithErrorDetails(details ...map[string]interface{}) *StubBuilder {
+	b.ext.Details = append(b.ext.Details, details...)
+	return b
+}
+
+// WithResponseHeader sends the header with the response
+func (b *StubBuilder) WithResponseHeader(key, value string) *StubBuilder {
+	if b.stub.Output.Headers == nil {
+		b.stub.Output.Headers = make(map[string]string)
+	}
+	b.stub.Output.Headers[key] = value
+	return b
+}
+
+// WithTrailer sends the trailer with the response
+func (b *StubBuilder) WithTrailer(key, value string) *StubBuilder {
+	if b.ext.Trailers == nil {
+		b.ext.Trailers = make(map[string]string)
+	}
+	b.ext.Trailers[key] = value
+	return b
+}
+
+// Times limits how many calls the stub answers before it is removed
+func (b *StubBuilder) Times(n int) *StubBuilder {
+	if n <= 0 {
+		b.err = fmt.Errorf("invalid times: %d", n)
+	}
+	b.ext.Times = n
+	return b
+}
+
+// Delay waits the duration before answering
+func (b *StubBuilder) Delay(delay time.Duration) *StubBuilder {
+	b.ext.Delay = &Latency{Fixed: delay}
+	return b
+}
+
+// WithLatency draws the delay before answering from the latency
+func (b *StubBuilder) WithLatency(latency Latency) *StubBuilder {
+	b.ext.Delay = &latency
+	return b
+}
+
+// Build returns the stub and its extension without adding them
+func (b *StubBuilder) Build() (*stuber.Stub, *StubExtension, error) {
+	if b.err != nil {
+		return nil, nil, b.err
+	}
+
+	if b.stub.Output.Data == nil {
+		b.stub.Output.Data = map[string]interface{}{}
+	}
+
+	return b.stub, b.ext, nil
+}
+
+// Add builds the stub and adds it
+func (b *StubBuilder) Add() error {
+	stub, ext, err := b.Build()
+	if err != nil {
+		return err
+	}
+
+	return b.add(stub, ext)
+}
+
+// addStubWithExtension adds a copy of the stub to every server
+func (m *MultiServerManager) addStubWithExtension(stub *stuber.Stub, ext *StubExtension) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var lastErr error
+	for _, mocker := range m.servers {
+		copied := *stub
+		copiedExt := *ext
+		if err := mocker.GetServer().AddStubWithExtension(&copied, &copiedExt); err != nil {
+			lastErr = fmt.Errorf("failed to add stub to server on %s: %w", mocker.GetServer().Addr(), err)
+		}
+	}
+
+	return lastErr
+}
+
+func setValue(values map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+	values[key] = value
+	return values
+}
+
+func mergeValues(values, data map[string]interface{}) map[string]interface{} {
+	for key, value := range data {
+		values = setValue(values, key, value)
+	}
+	return values
+}
@@ -0,0 +1,21 @@
+package gripmock
+
+import (
+	"fmt"
+
+	"github.com/Dmytro-Hladkykh/gripmock/internal/pbs"
+)
+
+// RegisterDescriptorBundle adds a serialized FileDescriptorSet that resolves
+// proto imports found in neither the proto directories nor the import paths,
+// e.g. company-wide protos embedded with go:embed from the output of
+// "buf build -o bundle.pb" or "protoc --include_imports --descriptor_set_out".
+// googleapis, grpc-gateway's openapiv2 options, protoc-gen-validate and
+// buf.validate are bundled already; files of a registered bundle take
+// precedence over them. Register bundles before creating servers.
+func RegisterDescriptorBundle(name string, data []byte) error {
+	if err := pbs.Register(name, data); err != nil {
+		return fmt.Errorf("failed to register descriptor bundle: %w", err)
+	}
+	return nil
+}
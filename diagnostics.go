@@ -0,0 +1,173 @@
+package gripmock
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/gripmock/stuber"
+)
+
+// maxNearMisses limits how many closest stubs are reported when nothing matches
+const maxNearMisses = 3
+
+// nearMiss describes where a request differs from a stub
+type nearMiss struct {
+	stub  *stuber.Stub
+	diffs []string
+}
+
+// findNearMisses returns the stubs closest to the request, fewest differences first
+func findNearMisses(stubs []*stuber.Stub, headers, data map[string]interface{}) []nearMiss {
+	misses := make([]nearMiss, 0, len(stubs))
+	for _, stub := range stubs {
+		// Like stuber, header rules only check the headers the stub lists
+		diffs := diffRules("headers.equals", stub.Headers.Equals, headers, true)
+		diffs = append(diffs, diffRules("headers.contains", stub.Headers.Contains, headers, true)...)
+		diffs = append(diffs, diffMatches("headers.matches", stub.Headers.Matches, headers)...)
+		diffs = append(diffs, diffRules("equals", stub.Input.Equals, data, false)...)
+		diffs = append(diffs, diffRules("contains", stub.Input.Contains, data, true)...)
+		diffs = append(diffs, diffMatches("matches", stub.Input.Matches, data)...)
+
+		misses = append(misses, nearMiss{stub: stub, diffs: diffs})
+	}
+
+	sort.SliceStable(misses, func(i, j int) bool {
+		return len(misses[i].diffs) < len(misses[j].diffs)
+	})
+
+	// Keep the closest of the probes of a stub with alternative inputs
+	seen := make(map[uuid.UUID]bool, len(misses))
+	misses = slices.DeleteFunc(misses, func(miss nearMiss) bool {
+		duplicate := seen[miss.stub.ID]
+		seen[miss.stub.ID] = true
+		return duplicate
+	})
+
+	if len(misses) > maxNearMisses {
+		misses = misses[:maxNearMisses]
+	}
+
+	return misses
+}
+
+// formatNearMisses renders the near misses for an error message
+func formatNearMisses(misses []nearMiss) string {
+	if len(misses) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("; closest stubs:")
+	for _, miss := range misses {
+		fmt.Fprintf(&b, "\n  stub %s (%d difference(s))", miss.stub.ID, len(miss.diffs))
+		for _, diff := range miss.diffs {
+			fmt.Fprintf(&b, "\n    %s", diff)
+		}
+	}
+
+	return b.String()
+}
+
+// diffRules lists the fields where actual differs from the expected values.
+// Partial rules (contains) ignore fields the stub does not mention.
+func diffRules(path string, expected, actual map[string]interface{}, partial bool) []string {
+	var diffs []string
+
+	for _, key := range sortedKeys(expected) {
+		fieldPath := path + "." + key
+		want := expected[key]
+		got, ok := actual[key]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: missing, expected %s", fieldPath, formatValue(want)))
+			continue
+		}
+
+		wantMap, wantIsMap := want.(map[string]interface{})
+		gotMap, gotIsMap := got.(map[string]interface{})
+		if wantIsMap && gotIsMap {
+			diffs = append(diffs, diffRules(fieldPath, wantMap, gotMap, partial)...)
+			continue
+		}
+
+		if !sameValue(want, got) {
+			diffs = append(diffs, fmt.Sprintf("%s: expected %s, got %s", fieldPath, formatValue(want), formatValue(got)))
+		}
+	}
+
+	if partial || len(expected) == 0 {
+		return diffs
+	}
+
+	for _, key := range sortedKeys(actual) {
+		if _, ok := expected[key]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s.%s: unexpected, got %s", path, key, formatValue(actual[key])))
+		}
+	}
+
+	return diffs
+}
+
+// diffMatches lists the fields whose values do not match the expected patterns
+func diffMatches(path string, patterns, actual map[string]interface{}) []string {
+	var diffs []string
+
+	for _, key := range sortedKeys(patterns) {
+		fieldPath := path + "." + key
+		pattern := patterns[key]
+		got, ok := actual[key]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: missing, expected to match %s", fieldPath, formatValue(pattern)))
+			continue
+		}
+
+		patternMap, patternIsMap := pattern.(map[string]interface{})
+		gotMap, gotIsMap := got.(map[string]interface{})
+		if patternIsMap && gotIsMap {
+			diffs = append(diffs, diffMatches(fieldPath, patternMap, gotMap)...)
+			continue
+		}
+
+		re, err := regexp.Compile(fmt.Sprint(pattern))
+		if err != nil || !re.MatchString(fmt.Sprint(got)) {
+			diffs = append(diffs, fmt.Sprintf("%s: %s does not match %s", fieldPath, formatValue(got), formatValue(pattern)))
+		}
+	}
+
+	return diffs
+}
+
+// sameValue compares values through their JSON form, so that e.g. int32(1) equals float64(1)
+func sameValue(a, b interface{}) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	if aErr != nil || bErr != nil {
+		return fmt.Sprint(a) == fmt.Sprint(b)
+	}
+
+	return bytes.Equal(aJSON, bJSON)
+}
+
+func formatValue(value interface{}) string {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(jsonData)
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,27 @@
+package gripmock
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gripmock/stuber"
+)
+
+func TestNearMissesIgnoreUnlistedHeaders(t *testing.T) {
+	stub := &stuber.Stub{
+		Headers: stuber.InputHeader{Equals: map[string]interface{}{"authorization": "token"}},
+		Input:   stuber.InputData{Equals: map[string]interface{}{"name": "alice"}},
+	}
+
+	misses := findNearMisses([]*stuber.Stub{stub},
+		map[string]interface{}{"authorization": "token", "x-request-id": "42"},
+		map[string]interface{}{"name": "bob"})
+	if len(misses) != 1 {
+		t.Fatalf("misses = %v, want one", misses)
+	}
+
+	want := []string{`equals.name: expected "alice", got "bob"`}
+	if !reflect.DeepEqual(misses[0].diffs, want) {
+		t.Fatalf("diffs = %q, want %q", misses[0].diffs, want)
+	}
+}
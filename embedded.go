@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/goccy/go-json"
 	"github.com/gripmock/stuber"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 var (
@@ -16,7 +19,7 @@ var (
 
 // InitEmbeddedGripmock initializes embedded gripmock servers
 // Call this once in TestMain or test setup
-func InitEmbeddedGripmock(protoDir string, ports []int) error {
+func InitEmbeddedGripmock(protoDir string, ports []int, opts ...Option) error {
 	var err error
 	initOnce.Do(func() {
 		globalManager = NewMultiServerManager()
@@ -27,6 +30,7 @@ func InitEmbeddedGripmock(protoDir string, ports []int) error {
 				Port:       port,
 				ProtoDir:   protoDir,
 				Identifier: fmt.Sprintf("gripmock-%d", i),
+				Options:    opts,
 			}
 		}
 
@@ -63,6 +67,16 @@ func Clear() error {
 	return nil
 }
 
+// SetServingStatus sets the health status of a service on all servers,
+// or of the servers as a whole for an empty service name
+func SetServingStatus(service string, status ServingStatus) error {
+	if globalManager == nil {
+		return fmt.Errorf("embedded gripmock not initialized - call InitEmbeddedGripmock first")
+	}
+	globalManager.SetServingStatus(service, status)
+	return nil
+}
+
 // GetActivePorts returns the ports of all running gripmock servers
 // Useful for debugging or integration with other services
 func GetActivePorts() []int {
@@ -86,6 +100,63 @@ func AddStubToPort(port int, service, method string, input, output interface{})
 	return mocker.AddStub(service, method, input, output)
 }
 
+// Calls returns the calls received by all gripmock servers for the given service and method
+func Calls(service, method string) []Call {
+	if globalManager == nil {
+		return nil
+	}
+	return globalManager.Calls(service, method)
+}
+
+// AssertCalled fails the test unless any gripmock server received a call to the method
+func AssertCalled(t TestingT, service, method string) bool {
+	t.Helper()
+	return assertCalled(t, Calls(service, method), service, method)
+}
+
+// AssertCalledTimes fails the test unless the gripmock servers received exactly n calls to the method
+func AssertCalledTimes(t TestingT, n int, service, method string) bool {
+	t.Helper()
+	return assertCalledTimes(t, Calls(service, method), n, service, method)
+}
+
+// Verify fails the test for calls that found no stub and, in strict mode, for unused stubs on any server
+func Verify(t TestingT) bool {
+	t.Helper()
+	if globalManager == nil {
+		t.Errorf("embedded gripmock not initialized - call InitEmbeddedGripmock first")
+		return false
+	}
+	return globalManager.Verify(t)
+}
+
+// Track clears all stubs and calls and verifies the leftovers when the test ends
+func Track(t CleanupT) {
+	t.Helper()
+	if err := Clear(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	t.Cleanup(func() {
+		Verify(t)
+	})
+}
+
+// ClientConnToPort returns a client connection to a specific gripmock server by port,
+// in memory for servers started with WithInMemory
+func ClientConnToPort(port int, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	if globalManager == nil {
+		return nil, fmt.Errorf("embedded gripmock not initialized - call InitEmbeddedGripmock first")
+	}
+
+	mocker, exists := globalManager.GetServer(port)
+	if !exists {
+		return nil, fmt.Errorf("no server running on port %d", port)
+	}
+
+	return mocker.ClientConn(opts...)
+}
+
 // IsRunning returns true if all gripmock servers are running
 func IsRunning() bool {
 	if globalManager == nil {
@@ -108,19 +179,83 @@ func NewEmbeddedMocker(server *Server) *EmbeddedMocker {
 
 // AddStub adds a stub for the given service and method with input/output matching
 func (m *EmbeddedMocker) AddStub(service, method string, input, output interface{}) error {
+	stubInput, inputs, err := createInput(input)
+	if err != nil {
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	stubOutput, ext, err := createOutput(output)
+	if err != nil {
+		return fmt.Errorf("invalid output: %w", err)
+	}
+	ext.Inputs = inputs
+
 	stub := &stuber.Stub{
 		Service: service,
 		Method:  method,
-		Input:   createInputData(input),
-		Output:  createOutput(output),
+		Input:   stubInput,
+		Output:  stubOutput,
 	}
 
-	return m.server.AddStub(stub)
+	return m.server.AddStubWithExtension(stub, ext)
 }
 
-// Clear removes all stubs from the server
+// Clear removes all stubs and recorded calls from the server
 func (m *EmbeddedMocker) Clear() {
 	m.server.ClearStubs()
+	m.server.ClearCalls()
+}
+
+// Calls returns the calls received for the given service and method
+func (m *EmbeddedMocker) Calls(service, method string) []Call {
+	return m.server.Calls(service, method)
+}
+
+// AssertCalled fails the test unless the method was called at least once
+func (m *EmbeddedMocker) AssertCalled(t TestingT, service, method string) bool {
+	t.Helper()
+	return assertCalled(t, m.Calls(service, method), service, method)
+}
+
+// AssertCalledTimes fails the test unless the method was called exactly n times
+func (m *EmbeddedMocker) AssertCalledTimes(t TestingT, n int, service, method string) bool {
+	t.Helper()
+	return assertCalledTimes(t, m.Calls(service, method), n, service, method)
+}
+
+// Verify fails the test for calls that found no stub and, in strict mode, for unused stubs
+func (m *EmbeddedMocker) Verify(t TestingT) bool {
+	t.Helper()
+	return m.server.Verify(t)
+}
+
+// Track clears all stubs and calls and verifies the leftovers when the test ends
+func (m *EmbeddedMocker) Track(t CleanupT) {
+	t.Helper()
+	m.Clear()
+	t.Cleanup(func() {
+		m.Verify(t)
+	})
+}
+
+// UsedStubs returns the stubs that answered at least one call
+func (m *EmbeddedMocker) UsedStubs() []*stuber.Stub {
+	return m.server.UsedStubs()
+}
+
+// UnusedStubs returns the stubs that have not answered any call yet
+func (m *EmbeddedMocker) UnusedStubs() []*stuber.Stub {
+	return m.server.UnusedStubs()
+}
+
+// SetServingStatus sets the health status of a service, or of the whole server for ""
+func (m *EmbeddedMocker) SetServingStatus(service string, status ServingStatus) {
+	m.server.SetServingStatus(service, status)
+}
+
+// ClientConn returns a client connection to the server, in memory if it runs in memory
+func (m *EmbeddedMocker) ClientConn(opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	return m.server.ClientConn(opts...)
 }
 
 // GetServer returns the underlying server instance
@@ -128,54 +263,303 @@ func (m *EmbeddedMocker) GetServer() *Server {
 	return m.server
 }
 
-// createInputData creates stuber.InputData from interface{}
-func createInputData(input interface{}) stuber.InputData {
+// inputKeys are the keys of an input holding matching rules rather than request data
+var inputKeys = map[string]bool{
+	"equals":           true,
+	"contains":         true,
+	"matches":          true,
+	"ignoreArrayOrder": true,
+	"inputs":           true,
+}
+
+// outputKeys are the keys of an output describing the response rather than its data
+var outputKeys = map[string]bool{
+	"data":         true,
+	"stream":       true,
+	"conversation": true,
+	"error":        true,
+	"code":         true,
+	"details":      true,
+	"headers":      true,
+	"trailers":     true,
+	"delay":        true,
+}
+
+// createInput creates the stub input and, from the "inputs" key, the inputs
+// of client-streaming calls or the alternative inputs of other calls
+func createInput(input interface{}) (stuber.InputData, []stuber.InputData, error) {
+	inputMap, ok := input.(map[string]interface{})
+	items, hasInputs := inputMap["inputs"]
+	if !ok || !hasInputs || !hasOnlyKeys(inputMap, inputKeys) {
+		data, err := createInputData(input)
+		return data, nil, err
+	}
+
+	list, err := toList(items)
+	if err != nil {
+		return stuber.InputData{}, nil, fmt.Errorf("%q: %w", "inputs", err)
+	}
+
+	inputs := make([]stuber.InputData, 0, len(list))
+	for i, item := range list {
+		data, err := createInputData(item)
+		if err != nil {
+			return stuber.InputData{}, nil, fmt.Errorf("%q item %d: %w", "inputs", i, err)
+		}
+		inputs = append(inputs, data)
+	}
+
+	// The remaining keys hold rules shared with the inputs
+	rules := copyMap(inputMap)
+	delete(rules, "inputs")
+
+	data, err := createInputData(rules)
+	return data, inputs, err
+}
+
+// createInputData creates stuber.InputData from a map of matching rules, or
+// from request data, given as a map or a struct, matched as "matches"
+func createInputData(input interface{}) (stuber.InputData, error) {
 	if input == nil {
 		return stuber.InputData{
 			Matches: map[string]interface{}{},
-		}
+		}, nil
+	}
+
+	inputMap, err := toMap(input)
+	if err != nil {
+		return stuber.InputData{}, err
 	}
 
-	if inputMap, ok := input.(map[string]interface{}); ok {
-		if matches, hasMatches := inputMap["matches"]; hasMatches {
-			return stuber.InputData{
-				Matches: matches.(map[string]interface{}),
+	if !hasOnlyKeys(inputMap, inputKeys) {
+		return stuber.InputData{
+			Matches: inputMap,
+		}, nil
+	}
+
+	var data stuber.InputData
+	for _, key := range sortedKeys(inputMap) {
+		value := inputMap[key]
+
+		var err error
+		switch key {
+		case "equals":
+			data.Equals, err = toMap(value)
+		case "contains":
+			data.Contains, err = toMap(value)
+		case "matches":
+			data.Matches, err = toMap(value)
+		case "ignoreArrayOrder":
+			var ok bool
+			if data.IgnoreArrayOrder, ok = value.(bool); !ok {
+				err = fmt.Errorf("expected a bool, got %T", value)
 			}
+		case "inputs":
+			err = fmt.Errorf("inputs cannot be nested")
+		default:
+			err = fmt.Errorf("unknown key next to matching rules")
 		}
-		if equals, hasEquals := inputMap["equals"]; hasEquals {
-			return stuber.InputData{
-				Equals: equals.(map[string]interface{}),
-			}
+		if err != nil {
+			return stuber.InputData{}, fmt.Errorf("%q: %w", key, err)
 		}
 	}
 
-	return stuber.InputData{
-		Matches: input.(map[string]interface{}),
-	}
+	return data, nil
 }
 
-// createOutput creates stuber.Output from interface{}
-func createOutput(output interface{}) stuber.Output {
+// createOutput creates stuber.Output and its extension from the response keys,
+// or from response data, given as a map or a struct
+func createOutput(output interface{}) (stuber.Output, *StubExtension, error) {
+	ext := &StubExtension{}
 	if output == nil {
 		return stuber.Output{
 			Data: map[string]interface{}{},
-		}
+		}, ext, nil
+	}
+
+	outputMap, err := toMap(output)
+	if err != nil {
+		return stuber.Output{}, nil, err
 	}
 
-	if outputMap, ok := output.(map[string]interface{}); ok {
-		if data, hasData := outputMap["data"]; hasData {
-			return stuber.Output{
-				Data: data.(map[string]interface{}),
+	if !hasOnlyKeys(outputMap, outputKeys) {
+		return stuber.Output{
+			Data: outputMap,
+		}, ext, nil
+	}
+
+	stubOutput := stuber.Output{
+		Data: map[string]interface{}{},
+	}
+	for _, key := range sortedKeys(outputMap) {
+		value := outputMap[key]
+
+		var err error
+		switch key {
+		case "data":
+			stubOutput.Data, err = toMap(value)
+		case "stream":
+			ext.Stream, err = createMapList(value)
+		case "conversation":
+			ext.Conversation, err = createConversation(value)
+		case "error":
+			var ok bool
+			if stubOutput.Error, ok = value.(string); !ok {
+				err = fmt.Errorf("expected a string, got %T", value)
+			}
+		case "code":
+			var code codes.Code
+			if code, err = parseCode(value); err == nil {
+				stubOutput.Code = &code
 			}
+		case "details":
+			ext.Details, err = createMapList(value)
+		case "headers":
+			stubOutput.Headers, err = createStringMap(value)
+		case "trailers":
+			ext.Trailers, err = createStringMap(value)
+		case "delay":
+			ext.Delay, err = parseLatency(value)
+		default:
+			err = fmt.Errorf("unknown key next to response keys")
+		}
+		if err != nil {
+			return stuber.Output{}, nil, fmt.Errorf("%q: %w", key, err)
+		}
+	}
+
+	return stubOutput, ext, nil
+}
+
+// createStringMap collects metadata values from a map of strings or of values formatted as strings
+func createStringMap(values interface{}) (map[string]string, error) {
+	if strings, ok := values.(map[string]string); ok {
+		return strings, nil
+	}
+
+	items, err := toMap(values)
+	if err != nil {
+		return nil, err
+	}
+
+	strings := make(map[string]string, len(items))
+	for k, v := range items {
+		if v == nil {
+			return nil, fmt.Errorf("%q has no value", k)
+		}
+		strings[k] = fmt.Sprint(v)
+	}
+
+	return strings, nil
+}
+
+// createMapList collects maps, such as stream messages, from a list of maps or structs
+func createMapList(list interface{}) ([]map[string]interface{}, error) {
+	if maps, ok := list.([]map[string]interface{}); ok {
+		return maps, nil
+	}
+
+	items, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	maps := make([]map[string]interface{}, 0, len(items))
+	for i, item := range items {
+		m, err := toMap(item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		maps = append(maps, m)
+	}
+
+	return maps, nil
+}
+
+// createConversation collects the bidirectional conversation steps, each a map with "input" and "output"
+func createConversation(conversation interface{}) ([]Exchange, error) {
+	items, err := toList(conversation)
+	if err != nil {
+		return nil, err
+	}
+
+	exchanges := make([]Exchange, 0, len(items))
+	for i, item := range items {
+		step, err := toMap(item)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
 		}
-		if errorMsg, hasError := outputMap["error"]; hasError {
-			return stuber.Output{
-				Error: errorMsg.(string),
+
+		for key := range step {
+			if key != "input" && key != "output" {
+				return nil, fmt.Errorf("step %d: unknown key %q", i, key)
 			}
 		}
+
+		input, err := createInputData(step["input"])
+		if err != nil {
+			return nil, fmt.Errorf("step %d input: %w", i, err)
+		}
+
+		output, err := toMap(step["output"])
+		if err != nil {
+			return nil, fmt.Errorf("step %d output: %w", i, err)
+		}
+
+		exchanges = append(exchanges, Exchange{
+			Input:  input,
+			Output: output,
+		})
 	}
 
-	return stuber.Output{
-		Data: output.(map[string]interface{}),
+	return exchanges, nil
+}
+
+// toMap returns a JSON object given as a map or, through a JSON round-trip, as a struct
+func toMap(value interface{}) (map[string]interface{}, error) {
+	if m, ok := value.(map[string]interface{}); ok {
+		return m, nil
 	}
+
+	var m map[string]interface{}
+	if err := roundTrip(value, &m); err != nil || m == nil {
+		return nil, fmt.Errorf("expected an object, got %T", value)
+	}
+
+	return m, nil
+}
+
+// toList returns a JSON array given as a slice of any type
+func toList(value interface{}) ([]interface{}, error) {
+	if list, ok := value.([]interface{}); ok {
+		return list, nil
+	}
+
+	var list []interface{}
+	if err := roundTrip(value, &list); err != nil || list == nil {
+		return nil, fmt.Errorf("expected a list, got %T", value)
+	}
+
+	return list, nil
+}
+
+func roundTrip(value, target interface{}) error {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(jsonData, target)
+}
+
+// hasOnlyKeys reports whether values is not empty and has no key other than
+// keys, so that data with a field named like one of the keys is kept as data
+func hasOnlyKeys(values map[string]interface{}, keys map[string]bool) bool {
+	for key := range values {
+		if !keys[key] {
+			return false
+		}
+	}
+
+	return len(values) > 0
 }
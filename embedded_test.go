@@ -0,0 +1,80 @@
+package gripmock
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCreateOutputKeepsDataNamedLikeResponseKeys(t *testing.T) {
+	for _, data := range []map[string]interface{}{
+		{"message": "ok", "code": float64(200)},
+		{"name": "x", "headers": []interface{}{"a", "b"}},
+		{"id": "1", "stream": "raw", "delay": "soon"},
+	} {
+		output, ext, err := createOutput(data)
+		if err != nil {
+			t.Fatalf("createOutput(%v): %v", data, err)
+		}
+		if !reflect.DeepEqual(output.Data, data) {
+			t.Fatalf("createOutput(%v) data = %v", data, output.Data)
+		}
+		if output.Code != nil || output.Headers != nil || ext.Stream != nil || ext.Delay != nil {
+			t.Fatalf("createOutput(%v) read data as response keys", data)
+		}
+	}
+}
+
+func TestCreateOutputReadsResponseKeys(t *testing.T) {
+	output, _, err := createOutput(map[string]interface{}{
+		"data":    map[string]interface{}{"message": "ok"},
+		"headers": map[string]interface{}{"x-id": "1"},
+		"code":    float64(5),
+		"error":   "not found",
+	})
+	if err != nil {
+		t.Fatalf("createOutput: %v", err)
+	}
+	if output.Data["message"] != "ok" || output.Headers["x-id"] != "1" || output.Error != "not found" {
+		t.Fatalf("unexpected output %+v", output)
+	}
+	if output.Code == nil || *output.Code != 5 {
+		t.Fatalf("code = %v, want 5", output.Code)
+	}
+}
+
+func TestCreateInputKeepsDataNamedLikeRuleKeys(t *testing.T) {
+	for _, data := range []map[string]interface{}{
+		{"name": "x", "contains": "y"},
+		{"id": "1", "inputs": []interface{}{"a"}},
+	} {
+		input, inputs, err := createInput(data)
+		if err != nil {
+			t.Fatalf("createInput(%v): %v", data, err)
+		}
+		if inputs != nil {
+			t.Fatalf("createInput(%v) read data as inputs: %v", data, inputs)
+		}
+		if !reflect.DeepEqual(input.Matches, data) {
+			t.Fatalf("createInput(%v) matches = %v", data, input.Matches)
+		}
+	}
+}
+
+func TestCreateInputReadsRuleKeys(t *testing.T) {
+	input, inputs, err := createInput(map[string]interface{}{
+		"contains": map[string]interface{}{"tenant": "a"},
+		"inputs": []interface{}{
+			map[string]interface{}{"equals": map[string]interface{}{"name": "x"}},
+			map[string]interface{}{"name": "y"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("createInput: %v", err)
+	}
+	if input.Contains["tenant"] != "a" {
+		t.Fatalf("contains = %v", input.Contains)
+	}
+	if len(inputs) != 2 || inputs[0].Equals["name"] != "x" || inputs[1].Matches["name"] != "y" {
+		t.Fatalf("inputs = %+v", inputs)
+	}
+}
@@ -0,0 +1,118 @@
+package gripmock
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/gripmock/stuber"
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails" // registers google.rpc detail types
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// defaultErrorCode is returned by stubs that set an error without a code
+const defaultErrorCode = codes.Aborted
+
+// typeURLPrefix is prepended to detail types given by their full name only
+const typeURLPrefix = "type.googleapis.com/"
+
+// stubError builds the gRPC status error the stub answers with,
+// or returns nil if the stub answers successfully
+func stubError(stub *stuber.Stub, ext *StubExtension, resolver *registry) error {
+	if stub.Output.Error == "" && stub.Output.Code == nil {
+		return nil
+	}
+
+	code := defaultErrorCode
+	if stub.Output.Code != nil {
+		code = *stub.Output.Code
+	}
+
+	if code == codes.OK {
+		return nil
+	}
+
+	st := status.New(code, stub.Output.Error).Proto()
+	for _, detail := range ext.Details {
+		value, err := newErrorDetail(detail, resolver)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to create error detail: %v", err)
+		}
+		st.Details = append(st.Details, value)
+	}
+
+	return status.FromProto(st).Err()
+}
+
+// newErrorDetail converts a detail map with an "@type" key, such as
+// google.rpc.ErrorInfo, into a typed status detail resolved by the resolver
+func newErrorDetail(detail map[string]interface{}, resolver *registry) (*anypb.Any, error) {
+	typeName, ok := detail["@type"].(string)
+	if !ok || typeName == "" {
+		return nil, fmt.Errorf("detail has no @type")
+	}
+
+	if !strings.Contains(typeName, "/") {
+		detail = copyMap(detail)
+		detail["@type"] = typeURLPrefix + typeName
+	}
+
+	jsonData, err := json.Marshal(detail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal detail %s: %w", typeName, err)
+	}
+
+	value := &anypb.Any{}
+	if err := (protojson.UnmarshalOptions{Resolver: resolver}).Unmarshal(jsonData, value); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal detail %s: %w", typeName, err)
+	}
+
+	return value, nil
+}
+
+// parseCode converts a code given as codes.Code, a number or a name like "NOT_FOUND"
+func parseCode(value interface{}) (codes.Code, error) {
+	switch v := value.(type) {
+	case codes.Code:
+		return v, nil
+	case int:
+		return numericCode(float64(v))
+	case int32:
+		return numericCode(float64(v))
+	case int64:
+		return numericCode(float64(v))
+	case uint32:
+		return numericCode(float64(v))
+	case float64:
+		return numericCode(v)
+	case string:
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(fmt.Sprintf("%q", strings.ToUpper(v)))); err != nil {
+			return 0, fmt.Errorf("invalid code %q: %w", v, err)
+		}
+		return code, nil
+	default:
+		return 0, fmt.Errorf("invalid code type %T", value)
+	}
+}
+
+// numericCode converts a code number, rejecting numbers like HTTP statuses that are no gRPC code
+func numericCode(v float64) (codes.Code, error) {
+	if v < float64(codes.OK) || v > float64(codes.Unauthenticated) || v != float64(int(v)) {
+		return 0, fmt.Errorf("invalid code %v", v)
+	}
+
+	return codes.Code(v), nil
+}
+
+func copyMap(src map[string]interface{}) map[string]interface{} {
+	dst := make(map[string]interface{}, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+
+	return dst
+}
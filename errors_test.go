@@ -0,0 +1,87 @@
+package gripmock
+
+import (
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStubErrorCodeAndMessage(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	if err := server.On("demo.Demo/Unary").ReturnsError(codes.NotFound, "no such user").Add(); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	_, err := callUnary(t, server, conn, "any")
+	st := status.Convert(err)
+	if st.Code() != codes.NotFound || st.Message() != "no such user" {
+		t.Fatalf("status = %v, want NotFound with the stub message", st)
+	}
+}
+
+func TestStubErrorWithoutCode(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := NewEmbeddedMocker(server).AddStub("demo.Demo", "Unary", nil, map[string]interface{}{"error": "boom"})
+	if err != nil {
+		t.Fatalf("AddStub: %v", err)
+	}
+
+	_, err = callUnary(t, server, conn, "any")
+	st := status.Convert(err)
+	if st.Code() != codes.Aborted || st.Message() != "boom" {
+		t.Fatalf("status = %v, want Aborted with the stub message", st)
+	}
+}
+
+func TestStubErrorDetails(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := NewEmbeddedMocker(server).AddStub("demo.Demo", "Unary", nil, map[string]interface{}{
+		"error": "user is locked",
+		"code":  "FAILED_PRECONDITION",
+		"details": []interface{}{
+			map[string]interface{}{"@type": "google.rpc.ErrorInfo", "reason": "LOCKED", "domain": "users"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("AddStub: %v", err)
+	}
+
+	_, err = callUnary(t, server, conn, "any")
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.FailedPrecondition {
+		t.Fatalf("status = %v, want FailedPrecondition", err)
+	}
+
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("details = %v, want one", details)
+	}
+
+	info, ok := details[0].(*errdetails.ErrorInfo)
+	if !ok || info.GetReason() != "LOCKED" || info.GetDomain() != "users" {
+		t.Fatalf("detail = %v, want the ErrorInfo of the stub", details[0])
+	}
+}
+
+func TestStubErrorUnknownDetailType(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := NewEmbeddedMocker(server).AddStub("demo.Demo", "Unary", nil, map[string]interface{}{
+		"error":   "denied",
+		"code":    "PERMISSION_DENIED",
+		"details": []interface{}{map[string]interface{}{"@type": "acme.Unknown", "reason": "x"}},
+	})
+	if err != nil {
+		t.Fatalf("AddStub: %v", err)
+	}
+
+	_, err = callUnary(t, server, conn, "any")
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("status = %v, want Internal", err)
+	}
+}
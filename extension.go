@@ -0,0 +1,125 @@
+package gripmock
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/gripmock/stuber"
+)
+
+// StubExtension holds stub behaviour that stuber.Stub has no room for
+type StubExtension struct {
+	// Stream lists the messages sent, in order, by server-streaming methods
+	Stream []map[string]interface{}
+	// Inputs lists the messages a client-streaming call must send, in order;
+	// for other methods, including each message of a bidirectional call, they
+	// are alternatives, any of which matches the request
+	Inputs []stuber.InputData
+	// Conversation scripts a bidirectional call as request/response steps
+	Conversation []Exchange
+	// Details are encoded as typed status details of the stub's error;
+	// each map names its type in "@type", e.g. google.rpc.ErrorInfo
+	Details []map[string]interface{}
+	// Trailers are sent as response trailers; binary "-bin" values are base64-encoded
+	Trailers map[string]string
+	// Delay is waited before answering, bounded by the caller's deadline
+	Delay *Latency
+	// Times limits how many calls the stub answers before it is removed; 0 is unlimited
+	Times int
+}
+
+// Exchange is one step of a bidirectional conversation: the expected
+// request and the message sent back for it
+type Exchange struct {
+	Input  stuber.InputData
+	Output map[string]interface{}
+}
+
+// scripted reports whether the stub is matched by its script rather than its input
+func (e *StubExtension) scripted() bool {
+	return len(e.Inputs) > 0 || len(e.Conversation) > 0
+}
+
+// extensionStore keeps stub extensions keyed by stub ID
+type extensionStore struct {
+	items map[uuid.UUID]*StubExtension
+	uses  map[uuid.UUID]int
+	mu    sync.RWMutex
+}
+
+func newExtensionStore() *extensionStore {
+	return &extensionStore{
+		items: make(map[uuid.UUID]*StubExtension),
+		uses:  make(map[uuid.UUID]int),
+	}
+}
+
+func (s *extensionStore) put(id uuid.UUID, ext *StubExtension) {
+	if ext == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[id] = ext
+}
+
+// get returns the extension of the stub, or an empty one if it has none
+func (s *extensionStore) get(id uuid.UUID) *StubExtension {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if ext, ok := s.items[id]; ok {
+		return ext
+	}
+
+	return &StubExtension{}
+}
+
+func (s *extensionStore) delete(ids ...uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.items, id)
+		delete(s.uses, id)
+	}
+}
+
+func (s *extensionStore) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = make(map[uuid.UUID]*StubExtension)
+	s.uses = make(map[uuid.UUID]int)
+}
+
+// claim counts a call answered by the stub and returns its extension. It
+// reports false if the stub is no longer in the store, e.g. because another
+// call used up its Times limit. The call using up the limit removes the stub
+// from the store and drops its extension and use count.
+func (s *extensionStore) claim(id uuid.UUID, store *stuber.Budgerigar) (*StubExtension, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if store.FindByID(id) == nil {
+		return nil, false
+	}
+
+	ext, found := s.items[id]
+	if !found {
+		return &StubExtension{}, true
+	}
+
+	if ext.Times > 0 {
+		s.uses[id]++
+		if s.uses[id] >= ext.Times {
+			store.DeleteByID(id)
+			delete(s.items, id)
+			delete(s.uses, id)
+		}
+	}
+
+	return ext, true
+}
@@ -5,7 +5,7 @@ go 1.24
 require (
 	github.com/cockroachdb/errors v1.3.0
 	github.com/goccy/go-json v0.10.5
-	github.com/google/uuid v1.6.0 // indirect
+	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
 	github.com/rs/zerolog v1.34.0
 	github.com/samber/lo v1.51.0
@@ -18,6 +18,7 @@ require (
 	github.com/bufbuild/protocompile v0.14.1
 	github.com/gripmock/stuber v1.8.3
 	github.com/oapi-codegen/runtime v1.1.2
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250528174236-200df99c418a
 )
 
 require (
@@ -37,5 +38,4 @@ require (
 	golang.org/x/sync v0.16.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
 	golang.org/x/text v0.28.0 // indirect
-	google.golang.org/genproto/googleapis/rpc v0.0.0-20250528174236-200df99c418a // indirect
 )
@@ -2,17 +2,21 @@ package gripmock
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
-	"strings"
+	"net/http"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/bavix/features"
+	"github.com/google/uuid"
 	"github.com/gripmock/stuber"
+	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
-	"google.golang.org/protobuf/reflect/protodesc"
-	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/health"
 	"google.golang.org/protobuf/types/descriptorpb"
 
 	"github.com/Dmytro-Hladkykh/gripmock/internal/proto"
@@ -20,27 +24,80 @@ import (
 
 // Server represents a simplified gRPC mock server
 type Server struct {
-	grpcServer *grpc.Server
-	listener   net.Listener
-	budgerigar *stuber.Budgerigar
-	port       int
-	protoFiles []string
-	mu         sync.RWMutex
-	running    bool
+	grpcServer  *grpc.Server
+	listener    net.Listener
+	adminServer *http.Server
+	adminAddr   net.Addr
+	budgerigar  *stuber.Budgerigar
+	extensions  *extensionStore
+	journal     *journal
+	services    []proto.Service
+	port        int
+	address     string
+	admin       bool
+	adminPort   int
+	protoFiles  []string
+	importPaths []string
+	cacheDir    string
+	strict      bool
+	inMemory    bool
+	tlsSettings *tlsSettings
+	serverName  string
+	serverTLS   *tls.Config
+	ca          *certificateAuthority
+	descriptors *proto.Descriptors
+	registry    *registry
+	logger      zerolog.Logger
+	health      *health.Server
+	statuses    map[string]ServingStatus
+	grpcOptions []grpc.ServerOption
+	compressor  string
+	mu          sync.RWMutex
+	running     bool
 }
 
 // NewServer creates a new simplified gRPC mock server
-func NewServer(port int, protoFiles []string) (*Server, error) {
-	if port <= 0 {
-		return nil, fmt.Errorf("invalid port: %d", port)
-	}
-
+func NewServer(port int, protoFiles []string, opts ...Option) (*Server, error) {
 	budgerigar := stuber.NewBudgerigar(features.New())
 
 	server := &Server{
 		budgerigar: budgerigar,
+		extensions: newExtensionStore(),
+		journal:    newJournal(),
 		port:       port,
 		protoFiles: protoFiles,
+		statuses:   make(map[string]ServingStatus),
+		logger:     zerolog.Nop(),
+	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	if port < 0 {
+		return nil, fmt.Errorf("invalid port: %d", port)
+	}
+
+	if server.adminPort < 0 {
+		return nil, fmt.Errorf("invalid admin port: %d", server.adminPort)
+	}
+
+	if server.compressor != "" && encoding.GetCompressor(server.compressor) == nil {
+		return nil, fmt.Errorf("unknown compressor: %s", server.compressor)
+	}
+
+	if network, address := server.listenAddress(); address == "" {
+		return nil, fmt.Errorf("invalid %s address: %q", network, server.address)
+	}
+
+	for _, path := range server.importPaths {
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("invalid import path: %s is not a directory", path)
+		}
+	}
+
+	if err := server.setupTLS(); err != nil {
+		return nil, fmt.Errorf("failed to set up TLS: %w", err)
 	}
 
 	if err := server.loadProtos(protoFiles); err != nil {
@@ -56,26 +113,47 @@ func (s *Server) Start(ctx context.Context) error {
 	defer s.mu.Unlock()
 
 	if s.running {
-		return fmt.Errorf("server already running on port %d", s.port)
+		return fmt.Errorf("server already running on %s", s.listener.Addr())
 	}
 
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	listener, err := s.listen()
 	if err != nil {
-		return fmt.Errorf("failed to listen on port %d: %w", s.port, err)
+		return err
 	}
 
+	grpcServer := grpc.NewServer(s.serverOptions()...)
 	s.listener = listener
-	s.grpcServer = grpc.NewServer()
+	s.grpcServer = grpcServer
+
+	s.registerServices()
 
-	if err := s.registerServices(ctx); err != nil {
-		listener.Close()
-		return fmt.Errorf("failed to register services: %w", err)
+	if s.admin {
+		adminListener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.adminPort))
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to listen on admin port %d: %w", s.adminPort, err)
+		}
+
+		adminServer := &http.Server{
+			Handler:           newAdminHandler(s),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		s.adminAddr = adminListener.Addr()
+		s.adminServer = adminServer
+
+		go func() {
+			if err := adminServer.Serve(adminListener); err != nil {
+				// Admin server stopped
+			}
+		}()
 	}
 
 	s.running = true
 
+	// The goroutines serve the local servers, as a later Stop and Start
+	// replace the fields without waiting for them
 	go func() {
-		if err := s.grpcServer.Serve(listener); err != nil {
+		if err := grpcServer.Serve(listener); err != nil {
 			// Server stopped
 		}
 	}()
@@ -92,8 +170,20 @@ func (s *Server) Stop() {
 		return
 	}
 
+	if s.adminServer != nil {
+		s.adminServer.Close()
+		s.adminServer = nil
+		s.adminAddr = nil
+	}
+
+	// Health watchers see the server going away
+	if s.health != nil {
+		s.health.Shutdown()
+		s.health = nil
+	}
+
 	if s.grpcServer != nil {
-		s.grpcServer.GracefulStop()
+		stopGracefully(s.grpcServer)
 	}
 
 	if s.listener != nil {
@@ -105,23 +195,139 @@ func (s *Server) Stop() {
 
 // AddStub adds a stub to the server
 func (s *Server) AddStub(stub *stuber.Stub) error {
+	return s.AddStubWithExtension(stub, nil)
+}
+
+// AddStubWithExtension adds a stub together with its extended response behaviour
+func (s *Server) AddStubWithExtension(stub *stuber.Stub, ext *StubExtension) error {
 	stubs := s.budgerigar.PutMany(stub)
 	if len(stubs) == 0 {
 		return fmt.Errorf("failed to add stub")
 	}
+	s.extensions.put(stubs[0], ext)
 	return nil
 }
 
+// DeleteStubs removes the stubs with the given IDs and returns how many were removed
+func (s *Server) DeleteStubs(ids ...uuid.UUID) int {
+	s.extensions.delete(ids...)
+	return s.budgerigar.DeleteByID(ids...)
+}
+
 // ClearStubs removes all stubs from the server
 func (s *Server) ClearStubs() {
 	s.budgerigar.Clear()
+	s.extensions.clear()
 }
 
-// GetPort returns the port the server is listening on
+// Calls returns the calls received for the method in the order they arrived;
+// an empty service or method matches any
+func (s *Server) Calls(service, method string) []Call {
+	return s.journal.list(service, method)
+}
+
+// ClearCalls forgets every recorded call
+func (s *Server) ClearCalls() {
+	s.journal.clear()
+}
+
+// UsedStubs returns the stubs that answered at least one call
+func (s *Server) UsedStubs() []*stuber.Stub {
+	hits := s.journal.hits()
+
+	var used []*stuber.Stub
+	for _, stub := range s.budgerigar.All() {
+		if hits[stub.ID] > 0 {
+			used = append(used, stub)
+		}
+	}
+	return used
+}
+
+// UnusedStubs returns the stubs that have not answered any call yet
+func (s *Server) UnusedStubs() []*stuber.Stub {
+	hits := s.journal.hits()
+
+	var unused []*stuber.Stub
+	for _, stub := range s.budgerigar.All() {
+		if hits[stub.ID] == 0 {
+			unused = append(unused, stub)
+		}
+	}
+	return unused
+}
+
+// Verify fails the test for every call that found no stub and, in strict
+// mode, for every stub that no call has hit
+func (s *Server) Verify(t TestingT) bool {
+	t.Helper()
+
+	ok := true
+	for _, call := range s.journal.list("", "") {
+		if !call.Matched() {
+			t.Errorf("unmatched call to %s with data %v and headers %v", methodPath(call.Service, call.Method), call.Data, call.Headers)
+			ok = false
+		}
+	}
+
+	if s.strict {
+		for _, stub := range s.UnusedStubs() {
+			t.Errorf("unused stub %s for %s with input %+v", stub.ID, methodPath(stub.Service, stub.Method), stub.Input)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// GetPort returns the port the server is listening on. For port 0 this is the
+// port assigned by the OS once the server is started.
 func (s *Server) GetPort() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if addr, ok := s.listenerAddr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
 	return s.port
 }
 
+// Addr returns the address the server is listening on, or nil if it is not running
+func (s *Server) Addr() net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.listenerAddr()
+}
+
+func (s *Server) listenerAddr() net.Addr {
+	if !s.running || s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// GetAdminPort returns the port of the admin REST API, or 0 if it is disabled.
+// For admin port 0 this is the port assigned by the OS once the server is started.
+func (s *Server) GetAdminPort() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if addr, ok := s.adminAddr.(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	if !s.admin {
+		return 0
+	}
+	return s.adminPort
+}
+
+// Services returns the services registered on the server with their methods
+func (s *Server) Services() []proto.Service {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.services
+}
+
 // IsRunning returns true if the server is currently running
 func (s *Server) IsRunning() bool {
 	s.mu.RLock()
@@ -131,6 +337,14 @@ func (s *Server) IsRunning() bool {
 
 // WaitForReady waits for the server to be ready to accept connections
 func (s *Server) WaitForReady(timeout time.Duration) error {
+	// The in-memory listener accepts connections as soon as the server runs
+	if s.inMemory {
+		if !s.IsRunning() {
+			return fmt.Errorf("server not running")
+		}
+		return nil
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
@@ -144,7 +358,8 @@ func (s *Server) WaitForReady(timeout time.Duration) error {
 		case <-ticker.C:
 			if s.IsRunning() {
 				// Try to connect to verify server is actually accepting connections
-				conn, err := net.DialTimeout("tcp", fmt.Sprintf("localhost:%d", s.port), 100*time.Millisecond)
+				network, address := s.dialAddress()
+				conn, err := net.DialTimeout(network, address, 100*time.Millisecond)
 				if err == nil {
 					conn.Close()
 					return nil
@@ -159,55 +374,66 @@ func (s *Server) loadProtos(protoFiles []string) error {
 		return fmt.Errorf("no proto files specified")
 	}
 
-	params := proto.New(protoFiles, []string{})
+	params := proto.New(protoFiles, s.importPaths)
 
-	// Load the proto descriptors and register them in global registry
-	descriptors, err := proto.Build(context.Background(), params.Imports(), params.ProtoPath())
+	descriptors, err := proto.BuildWithCache(s.logger.WithContext(context.Background()), s.cacheDir, params.Imports(), params.ProtoPath())
 	if err != nil {
 		return fmt.Errorf("failed to build proto descriptors: %w", err)
 	}
 
-	// Register file descriptors in global registry
-	for _, descriptor := range descriptors {
-		for _, file := range descriptor.GetFile() {
-			if _, err := protoregistry.GlobalFiles.FindFileByPath(file.GetName()); err != nil {
-				// File not found in registry, register it
-				fileDesc, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
-				if err != nil {
-					return fmt.Errorf("failed to create file descriptor for %s: %w", file.GetName(), err)
-				}
-				if err := protoregistry.GlobalFiles.RegisterFile(fileDesc); err != nil {
-					// Ignore if already registered
-					if !strings.Contains(err.Error(), "already registered") {
-						return fmt.Errorf("failed to register file descriptor for %s: %w", file.GetName(), err)
-					}
-				}
-			}
-		}
+	// Each set of descriptors gets its own registry, so servers may load
+	// different versions of the same proto files
+	s.registry, err = registryFor(descriptors)
+	if err != nil {
+		return err
 	}
 
+	s.descriptors = descriptors
+
 	return nil
 }
 
-func (s *Server) registerServices(ctx context.Context) error {
-	params := proto.New(s.protoFiles, []string{})
+func (s *Server) registerServices() {
+	s.services = nil
 
-	descriptors, err := proto.Build(ctx, params.Imports(), params.ProtoPath())
-	if err != nil {
-		return fmt.Errorf("failed to build descriptors: %w", err)
-	}
-
-	for _, descriptor := range descriptors {
+	for _, descriptor := range s.descriptors.Sets {
 		for _, file := range descriptor.GetFile() {
 			for _, svc := range file.GetService() {
 				serviceDesc := s.createServiceDesc(file, svc)
 				s.registerServiceMethods(&serviceDesc, svc)
 				s.grpcServer.RegisterService(&serviceDesc, nil)
+				s.services = append(s.services, newAPIService(file, serviceDesc.ServiceName, svc))
 			}
 		}
 	}
 
-	return nil
+	s.registerReflection()
+	s.registerHealth()
+}
+
+// mocked reports whether a service of the compiled protos has the name, so that
+// a built-in service of the same name is not registered next to it
+func (s *Server) mocked(service string) bool {
+	_, ok := s.grpcServer.GetServiceInfo()[service]
+	return ok
+}
+
+func newAPIService(file *descriptorpb.FileDescriptorProto, serviceName string, svc *descriptorpb.ServiceDescriptorProto) proto.Service {
+	service := proto.Service{
+		Id:      serviceName,
+		Name:    svc.GetName(),
+		Package: file.GetPackage(),
+		Methods: make([]proto.Method, 0, len(svc.GetMethod())),
+	}
+
+	for _, method := range svc.GetMethod() {
+		service.Methods = append(service.Methods, proto.Method{
+			Id:   fmt.Sprintf("%s/%s", serviceName, method.GetName()),
+			Name: method.GetName(),
+		})
+	}
+
+	return service
 }
 
 func (s *Server) createServiceDesc(file *descriptorpb.FileDescriptorProto, svc *descriptorpb.ServiceDescriptorProto) grpc.ServiceDesc {
@@ -226,8 +452,14 @@ func (s *Server) registerServiceMethods(serviceDesc *grpc.ServiceDesc, svc *desc
 	for _, method := range svc.GetMethod() {
 		mocker := &SimpleMocker{
 			budgerigar:      s.budgerigar,
+			extensions:      s.extensions,
+			journal:         s.journal,
+			registry:        s.registry,
+			logger:          s.logger,
 			fullServiceName: serviceDesc.ServiceName,
 			methodName:      method.GetName(),
+			serverStreams:   method.GetServerStreaming(),
+			clientStreams:   method.GetClientStreaming(),
 		}
 
 		if method.GetServerStreaming() || method.GetClientStreaming() {
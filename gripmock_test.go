@@ -0,0 +1,43 @@
+package gripmock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// startServer starts an in-memory server for the protos and returns a client
+// connection to it; both are closed when the test ends
+func startServer(t *testing.T, protoFiles []string, opts ...Option) (*Server, *grpc.ClientConn) {
+	t.Helper()
+
+	server, err := NewServer(0, protoFiles, append([]Option{WithInMemory()}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(server.Stop)
+
+	conn, err := server.ClientConn()
+	if err != nil {
+		t.Fatalf("ClientConn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return server, conn
+}
+
+// testContext returns a context that ends with the test or after a few seconds
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	return ctx
+}
@@ -0,0 +1,80 @@
+package gripmock
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/Dmytro-Hladkykh/gripmock/internal/proto"
+)
+
+// ServingStatus is the status reported by the grpc.health.v1.Health service
+type ServingStatus = proto.ServingStatus
+
+// Serving statuses of the health service
+const (
+	Unknown        = proto.Unknown
+	Serving        = proto.Serving
+	NotServing     = proto.NotServing
+	ServiceUnknown = proto.ServiceUnknown
+)
+
+// stopTimeout bounds how long Stop waits for pending RPCs, such as health
+// watches or open streams, before closing their connections
+const stopTimeout = time.Second
+
+// registerHealth registers the grpc.health.v1.Health service. Every registered
+// service and the server as a whole ("") are serving, unless set otherwise
+// with SetServingStatus. A health service compiled from the protos is stubbed
+// like any other service instead.
+func (s *Server) registerHealth() {
+	if s.mocked(healthv1.Health_ServiceDesc.ServiceName) {
+		return
+	}
+
+	s.health = health.NewServer()
+
+	s.health.SetServingStatus("", healthv1.HealthCheckResponse_SERVING)
+	for _, service := range s.services {
+		s.health.SetServingStatus(service.Id, healthv1.HealthCheckResponse_SERVING)
+	}
+
+	for service, status := range s.statuses {
+		s.health.SetServingStatus(service, healthv1.HealthCheckResponse_ServingStatus(status))
+	}
+
+	healthv1.RegisterHealthServer(s.grpcServer, s.health)
+}
+
+// SetServingStatus sets the health status of a service, or of the whole server
+// for an empty service name. Health watchers are notified of the change, and
+// the status is kept when the server is restarted. A health service stubbed
+// from the protos answers from its stubs instead.
+func (s *Server) SetServingStatus(service string, status ServingStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.statuses[service] = status
+	if s.health != nil {
+		s.health.SetServingStatus(service, healthv1.HealthCheckResponse_ServingStatus(status))
+	}
+}
+
+// stopGracefully stops the gRPC server, closing the connections of RPCs
+// still pending after stopTimeout
+func stopGracefully(server *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		server.Stop()
+		<-done
+	}
+}
@@ -0,0 +1,51 @@
+package gripmock
+
+import (
+	"testing"
+
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestBuiltInHealthService(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+	client := healthv1.NewHealthClient(conn)
+
+	resp, err := client.Check(testContext(t), &healthv1.HealthCheckRequest{Service: "demo.Demo"})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if resp.GetStatus() != healthv1.HealthCheckResponse_SERVING {
+		t.Fatalf("status = %v, want SERVING", resp.GetStatus())
+	}
+
+	server.SetServingStatus("demo.Demo", NotServing)
+
+	resp, err = client.Check(testContext(t), &healthv1.HealthCheckRequest{Service: "demo.Demo"})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if resp.GetStatus() != healthv1.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status = %v, want NOT_SERVING", resp.GetStatus())
+	}
+}
+
+// A vendored health.proto is stubbed like any other service instead of
+// clashing with the built-in health service
+func TestMockedHealthService(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/health"})
+
+	err := server.On("grpc.health.v1.Health/Check").
+		Returns(map[string]interface{}{"status": "NOT_SERVING"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	resp, err := healthv1.NewHealthClient(conn).Check(testContext(t), &healthv1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if resp.GetStatus() != healthv1.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status = %v, want the stubbed NOT_SERVING", resp.GetStatus())
+	}
+}
@@ -1,7 +1,10 @@
 package pbs
 
 import (
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
+	"sync"
 
 	"github.com/bufbuild/protocompile"
 	"github.com/cockroachdb/errors"
@@ -10,41 +13,149 @@ import (
 	"google.golang.org/protobuf/types/descriptorpb"
 )
 
-//go:embed googleapis.pb
-var googleapis []byte
+// The bundles other than protobuf.pb are generated from the modules pinned in gen/go.mod.
+//
+//go:generate go run -C gen . -dir ..
 
 //go:embed protobuf.pb
 var protobuf []byte
 
-type ThirdPartyResolver struct {
-	items []*descriptorpb.FileDescriptorSet
+//go:embed googleapis.pb
+var googleapis []byte
+
+//go:embed openapiv2.pb
+var openapiv2 []byte
+
+//go:embed validate.pb
+var validate []byte
+
+//go:embed protovalidate.pb
+var protovalidate []byte
+
+var errBundleRegistered = errors.New("bundle already registered")
+
+// bundle is a named set of descriptors resolving imports missing from the import paths
+type bundle struct {
+	name  string
+	files map[string]*descriptorpb.FileDescriptorProto
 }
 
-func NewResolver() (*ThirdPartyResolver, error) {
-	resolver := &ThirdPartyResolver{
-		items: make([]*descriptorpb.FileDescriptorSet, 0, 2), //nolint:mnd
+func newBundle(name string, data []byte) (*bundle, error) {
+	fds := &descriptorpb.FileDescriptorSet{}
+
+	err := proto.Unmarshal(data, fds)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal descriptor bundle: %s", name)
+	}
+
+	b := &bundle{name: name, files: make(map[string]*descriptorpb.FileDescriptorProto, len(fds.GetFile()))}
+	for _, file := range fds.GetFile() {
+		b.files[file.GetName()] = file
+	}
+
+	return b, nil
+}
+
+// bundleSet holds the embedded bundles and those registered with Register
+type bundleSet struct {
+	mu          sync.RWMutex
+	bundles     []*bundle
+	fingerprint string
+}
+
+func (s *bundleSet) add(name string, data []byte) error {
+	for _, b := range s.bundles {
+		if b.name == name {
+			return errors.Wrap(errBundleRegistered, name)
+		}
+	}
+
+	b, err := newBundle(name, data)
+	if err != nil {
+		return err
 	}
 
-	for _, pb := range [][]byte{googleapis, protobuf} {
-		fds := &descriptorpb.FileDescriptorSet{}
+	sum := sha256.Sum256(data)
+	h := sha256.New()
+	h.Write([]byte(s.fingerprint + "\n" + name + "\n"))
+	h.Write(sum[:])
+
+	s.bundles = append(s.bundles, b)
+	s.fingerprint = hex.EncodeToString(h.Sum(nil))
+
+	return nil
+}
+
+var embedded = sync.OnceValues(func() (*bundleSet, error) {
+	set := &bundleSet{}
 
-		err := proto.Unmarshal(pb, fds)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to unmarshal descriptor: %s", pb)
+	for _, b := range []struct {
+		name string
+		data []byte
+	}{
+		{"protobuf", protobuf},
+		{"googleapis", googleapis},
+		{"openapiv2", openapiv2},
+		{"validate", validate},
+		{"protovalidate", protovalidate},
+	} {
+		if err := set.add(b.name, b.data); err != nil {
+			return nil, err
 		}
+	}
+
+	return set, nil
+})
+
+// Register adds a bundle of descriptors, a serialized FileDescriptorSet, that
+// resolves imports missing from the import paths. Files of bundles registered
+// later take precedence over the same files of earlier and embedded bundles.
+func Register(name string, data []byte) error {
+	set, err := embedded()
+	if err != nil {
+		return err
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	return set.add(name, data)
+}
 
-		resolver.items = append(resolver.items, fds)
+// Fingerprint identifies the embedded and registered bundles, so that caches
+// of files compiled against them expire when they change.
+func Fingerprint() string {
+	set, err := embedded()
+	if err != nil {
+		return ""
 	}
 
-	return resolver, nil
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	return set.fingerprint
+}
+
+type ThirdPartyResolver struct {
+	items []*bundle
+}
+
+func NewResolver() (*ThirdPartyResolver, error) {
+	set, err := embedded()
+	if err != nil {
+		return nil, err
+	}
+
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	return &ThirdPartyResolver{items: append([]*bundle(nil), set.bundles...)}, nil
 }
 
 func (p *ThirdPartyResolver) FindFileByPath(path string) (protocompile.SearchResult, error) {
-	for _, pb := range p.items {
-		for _, file := range pb.GetFile() {
-			if file.GetName() == path {
-				return protocompile.SearchResult{Proto: file}, nil
-			}
+	for i := len(p.items) - 1; i >= 0; i-- {
+		if file, ok := p.items[i].files[path]; ok {
+			return protocompile.SearchResult{Proto: file}, nil
 		}
 	}
 
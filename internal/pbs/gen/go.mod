@@ -0,0 +1,21 @@
+module github.com/Dmytro-Hladkykh/gripmock/internal/pbs/gen
+
+go 1.25.0
+
+require (
+	buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go v1.36.11-20260709200747-435963d16310.1
+	cloud.google.com/go/longrunning v0.8.0
+	github.com/envoyproxy/protoc-gen-validate v1.3.3
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.29.0
+	google.golang.org/genproto v0.0.0-20250603155806-513f23925822
+	google.golang.org/genproto/googleapis/api v0.0.0-20260720211330-0afa2a65878a
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260720211330-0afa2a65878a
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	golang.org/x/net v0.55.0 // indirect
+	golang.org/x/sys v0.45.0 // indirect
+	golang.org/x/text v0.37.0 // indirect
+	google.golang.org/grpc v1.80.0 // indirect
+)
@@ -0,0 +1,131 @@
+// Command gen writes the third-party descriptor bundles embedded by package pbs
+// from the generated Go packages of the modules pinned in go.mod. Bump those
+// modules to update the bundles, then run go generate in package pbs.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	_ "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	_ "cloud.google.com/go/longrunning/autogen/longrunningpb"
+	_ "github.com/envoyproxy/protoc-gen-validate/validate"
+	_ "github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-openapiv2/options"
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	_ "google.golang.org/genproto/googleapis/api/configchange"
+	_ "google.golang.org/genproto/googleapis/api/distribution"
+	_ "google.golang.org/genproto/googleapis/api/httpbody"
+	_ "google.golang.org/genproto/googleapis/api/label"
+	_ "google.golang.org/genproto/googleapis/api/metric"
+	_ "google.golang.org/genproto/googleapis/api/monitoredres"
+	_ "google.golang.org/genproto/googleapis/api/serviceconfig"
+	_ "google.golang.org/genproto/googleapis/api/visibility"
+	_ "google.golang.org/genproto/googleapis/rpc/code"
+	_ "google.golang.org/genproto/googleapis/rpc/context/attribute_context"
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails"
+	_ "google.golang.org/genproto/googleapis/rpc/http"
+	_ "google.golang.org/genproto/googleapis/rpc/status"
+	_ "google.golang.org/genproto/googleapis/type/calendarperiod"
+	_ "google.golang.org/genproto/googleapis/type/color"
+	_ "google.golang.org/genproto/googleapis/type/date"
+	_ "google.golang.org/genproto/googleapis/type/datetime"
+	_ "google.golang.org/genproto/googleapis/type/dayofweek"
+	_ "google.golang.org/genproto/googleapis/type/decimal"
+	_ "google.golang.org/genproto/googleapis/type/expr"
+	_ "google.golang.org/genproto/googleapis/type/fraction"
+	_ "google.golang.org/genproto/googleapis/type/interval"
+	_ "google.golang.org/genproto/googleapis/type/latlng"
+	_ "google.golang.org/genproto/googleapis/type/localized_text"
+	_ "google.golang.org/genproto/googleapis/type/money"
+	_ "google.golang.org/genproto/googleapis/type/month"
+	_ "google.golang.org/genproto/googleapis/type/phone_number"
+	_ "google.golang.org/genproto/googleapis/type/postaladdress"
+	_ "google.golang.org/genproto/googleapis/type/quaternion"
+	_ "google.golang.org/genproto/googleapis/type/timeofday"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// bundles maps each bundle file to the path prefixes of the files it holds
+var bundles = map[string][]string{
+	"googleapis.pb":    {"google/api/", "google/longrunning/", "google/rpc/", "google/type/"},
+	"openapiv2.pb":     {"protoc-gen-openapiv2/"},
+	"validate.pb":      {"validate/"},
+	"protovalidate.pb": {"buf/validate/"},
+}
+
+func main() {
+	dir := flag.String("dir", ".", "directory to write the bundles to")
+	flag.Parse()
+
+	for name, prefixes := range bundles {
+		if err := write(filepath.Join(*dir, name), prefixes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// write writes the registered files under the prefixes to the bundle,
+// each after the files it imports
+func write(name string, prefixes []string) error {
+	var files []protoreflect.FileDescriptor
+	protoregistry.GlobalFiles.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		if hasAnyPrefix(file.Path(), prefixes) {
+			files = append(files, file)
+		}
+		return true
+	})
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path() < files[j].Path() })
+
+	fds := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+
+	var add func(file protoreflect.FileDescriptor)
+	add = func(file protoreflect.FileDescriptor) {
+		if seen[file.Path()] || !hasAnyPrefix(file.Path(), prefixes) {
+			return
+		}
+		seen[file.Path()] = true
+
+		imports := file.Imports()
+		for i := range imports.Len() {
+			add(imports.Get(i).FileDescriptor)
+		}
+		fds.File = append(fds.File, protodesc.ToFileDescriptorProto(file))
+	}
+
+	for _, file := range files {
+		add(file)
+	}
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(fds)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	fmt.Printf("%s: %d files\n", filepath.Base(name), len(fds.File))
+
+	return nil
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,233 @@
+package proto
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/Dmytro-Hladkykh/gripmock/internal/pbs"
+)
+
+// cacheFormat is hashed into every cache key, so that a change to the cache
+// file layout or the way files are compiled never reuses older entries
+const cacheFormat = "gripmock-descriptors-v1"
+
+// descriptorCache holds the descriptors compiled in this process by the hash of
+// their inputs, so that servers loading the same protos compile them once.
+// Cached descriptors are shared and must not be modified.
+type descriptorCache struct {
+	mu      sync.Mutex
+	entries map[string]*Descriptors
+}
+
+var compiled = &descriptorCache{entries: make(map[string]*Descriptors)}
+
+func (c *descriptorCache) get(key string) (*Descriptors, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	descriptors, ok := c.entries[key]
+
+	return descriptors, ok
+}
+
+func (c *descriptorCache) put(key string, descriptors *Descriptors) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = descriptors
+}
+
+// compileCached compiles the configuration unless descriptors for the same
+// inputs were compiled before, in this process or, with a cacheDir, in an
+// earlier one
+func compileCached(ctx context.Context, configure *Configure, cacheDir string) (*Descriptors, error) {
+	logger := zerolog.Ctx(ctx)
+
+	key, err := inputHash(configure)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash inputs")
+	}
+
+	if descriptors, ok := compiled.get(key); ok {
+		logger.Debug().Str("key", key).Msg("Reusing compiled descriptors")
+
+		return descriptors, nil
+	}
+
+	if cacheDir != "" {
+		descriptors, err := readCacheFile(cachePath(cacheDir, key))
+
+		switch {
+		case err == nil:
+			logger.Debug().Str("key", key).Msg("Loaded descriptors from cache")
+			compiled.put(key, descriptors)
+
+			return descriptors, nil
+		case !errors.Is(err, fs.ErrNotExist):
+			logger.Warn().Err(err).Str("key", key).Msg("Ignoring unreadable descriptor cache")
+		}
+	}
+
+	descriptors, err := compile(ctx, configure)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled.put(key, descriptors)
+
+	if cacheDir != "" {
+		if err := writeCacheFile(cacheDir, key, descriptors); err != nil {
+			logger.Warn().Err(err).Str("key", key).Msg("Failed to write descriptor cache")
+		}
+	}
+
+	return descriptors, nil
+}
+
+// cachePath names cache files with an extension the processor skips, so that
+// a cache directory inside a proto directory is never compiled
+func cachePath(cacheDir, key string) string {
+	return filepath.Join(cacheDir, key+".descriptors")
+}
+
+// readCacheFile reads descriptors written by writeCacheFile
+func readCacheFile(path string) (*Descriptors, error) {
+	data, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read descriptor cache: %s", path)
+	}
+
+	reader := bytes.NewReader(data)
+
+	descriptors := &Descriptors{Imports: &descriptorpb.FileDescriptorSet{}}
+	if err := protodelim.UnmarshalFrom(reader, descriptors.Imports); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal descriptor cache: %s", path)
+	}
+
+	for {
+		fds := &descriptorpb.FileDescriptorSet{}
+
+		err := protodelim.UnmarshalFrom(reader, fds)
+		if errors.Is(err, io.EOF) {
+			return descriptors, nil
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal descriptor cache: %s", path)
+		}
+
+		descriptors.Sets = append(descriptors.Sets, fds)
+	}
+}
+
+// writeCacheFile writes the imports and then each set as size-delimited
+// messages. The file is renamed into place, so that concurrent test
+// processes never read a partial file.
+func writeCacheFile(cacheDir, key string, descriptors *Descriptors) error {
+	var buf bytes.Buffer
+
+	for _, fds := range append([]*descriptorpb.FileDescriptorSet{descriptors.Imports}, descriptors.Sets...) {
+		if _, err := protodelim.MarshalTo(&buf, fds); err != nil {
+			return errors.Wrap(err, "failed to marshal descriptors")
+		}
+	}
+
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return errors.Wrapf(err, "failed to create cache directory: %s", cacheDir)
+	}
+
+	file, err := os.CreateTemp(cacheDir, key+".*.tmp")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create cache file in %s", cacheDir)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+
+		return errors.Wrapf(err, "failed to write cache file: %s", file.Name())
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.Wrapf(err, "failed to write cache file: %s", file.Name())
+	}
+
+	return errors.Wrap(os.Rename(file.Name(), cachePath(cacheDir, key)), "failed to store cache file")
+}
+
+// inputHash hashes the embedded third-party descriptors, the import paths with
+// every proto file below them, the protos to compile and the descriptor files,
+// so that changing any file that may be compiled or imported changes the hash
+func inputHash(configure *Configure) (string, error) {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "%s %s\n", cacheFormat, pbs.Fingerprint())
+
+	for _, importPath := range slices.Sorted(slices.Values(configure.Imports())) {
+		fmt.Fprintf(h, "import %s\n", importPath)
+
+		err := filepath.WalkDir(importPath, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if entry.IsDir() || filepath.Ext(path) != ProtoExt {
+				return nil
+			}
+
+			return hashFile(h, "source", path)
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to walk import path: %s", importPath)
+		}
+	}
+
+	for _, proto := range slices.Sorted(slices.Values(configure.Protos())) {
+		fmt.Fprintf(h, "proto %s\n", proto)
+	}
+
+	for _, descriptor := range slices.Sorted(slices.Values(configure.Descriptors())) {
+		if err := hashFile(h, "descriptor", descriptor); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile writes the kind, path, size and content of the file to the hash
+func hashFile(h hash.Hash, kind, path string) error {
+	file, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file: %s", path)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat file: %s", path)
+	}
+
+	fmt.Fprintf(h, "%s %s %d\n", kind, path, info.Size())
+
+	if _, err := io.Copy(h, file); err != nil {
+		return errors.Wrapf(err, "failed to read file: %s", path)
+	}
+
+	return nil
+}
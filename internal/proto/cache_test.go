@@ -0,0 +1,89 @@
+package proto
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCacheFileRoundTrip(t *testing.T) {
+	descriptors, err := Build(context.Background(), []string{"testdata/nested/vendor"}, []string{"testdata/nested"})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := writeCacheFile(dir, "key", descriptors); err != nil {
+		t.Fatalf("writeCacheFile: %v", err)
+	}
+
+	cached, err := readCacheFile(cachePath(dir, "key"))
+	if err != nil {
+		t.Fatalf("readCacheFile: %v", err)
+	}
+
+	if !proto.Equal(cached.Imports, descriptors.Imports) {
+		t.Fatalf("cached imports = %v, want %v", cached.Imports, descriptors.Imports)
+	}
+
+	if len(cached.Sets) != len(descriptors.Sets) {
+		t.Fatalf("cached %d sets, want %d", len(cached.Sets), len(descriptors.Sets))
+	}
+
+	for i := range descriptors.Sets {
+		if !proto.Equal(cached.Sets[i], descriptors.Sets[i]) {
+			t.Fatalf("cached set %d = %v, want %v", i, cached.Sets[i], descriptors.Sets[i])
+		}
+	}
+}
+
+// Editing a compiled or an imported proto changes the cache key
+func TestInputHashChangesWithProtos(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, os.DirFS("testdata/nested")); err != nil {
+		t.Fatalf("CopyFS: %v", err)
+	}
+
+	hash := func() string {
+		t.Helper()
+
+		configure, err := newConfigure(context.Background(), []string{filepath.Join(dir, "vendor")}, []string{dir})
+		if err != nil {
+			t.Fatalf("newConfigure: %v", err)
+		}
+
+		key, err := inputHash(configure)
+		if err != nil {
+			t.Fatalf("inputHash: %v", err)
+		}
+
+		return key
+	}
+
+	key := hash()
+	if again := hash(); again != key {
+		t.Fatalf("inputHash = %s then %s for the same protos", key, again)
+	}
+
+	for _, file := range []string{"svc.proto", "vendor/common/c.proto"} {
+		path := filepath.Join(dir, file)
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+
+		if err := os.WriteFile(path, append(content, "\n// edited\n"...), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+
+		edited := hash()
+		if edited == key {
+			t.Fatalf("inputHash did not change after editing %s", file)
+		}
+		key = edited
+	}
+}
@@ -14,7 +14,7 @@ import (
 	"github.com/samber/lo"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protodesc"
-	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/descriptorpb"
 
 	"github.com/Dmytro-Hladkykh/gripmock/internal/pbs"
@@ -31,6 +31,14 @@ const (
 
 var errUnsupportedFileType = errors.New("unsupported file type")
 
+// Descriptors are the file descriptor sets compiled from proto and descriptor files.
+// Imports holds the files they import that were not compiled themselves, so that
+// the sets resolve without any global registry.
+type Descriptors struct {
+	Sets    []*descriptorpb.FileDescriptorSet
+	Imports *descriptorpb.FileDescriptorSet
+}
+
 type Configure struct {
 	imports     []string
 	protos      []string
@@ -41,10 +49,10 @@ func (c *Configure) Imports() []string     { return c.imports }
 func (c *Configure) Protos() []string      { return c.protos }
 func (c *Configure) Descriptors() []string { return c.descriptors }
 
-func createDescriptorSet(ctx context.Context, configure *Configure) (*descriptorpb.FileDescriptorSet, error) {
+func createDescriptorSet(ctx context.Context, configure *Configure) (*descriptorpb.FileDescriptorSet, *descriptorpb.FileDescriptorSet, error) {
 	failbackResolver, err := pbs.NewResolver()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create fallback resolver")
+		return nil, nil, errors.Wrap(err, "failed to create fallback resolver")
 	}
 
 	compiler := protocompile.Compiler{
@@ -58,43 +66,53 @@ func createDescriptorSet(ctx context.Context, configure *Configure) (*descriptor
 
 	files, err := compiler.Compile(ctx, configure.Protos()...)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to compile descriptors")
+		return nil, nil, errors.Wrap(err, "failed to compile descriptors")
 	}
 
 	fds := &descriptorpb.FileDescriptorSet{
 		File: make([]*descriptorpb.FileDescriptorProto, len(files)),
 	}
 
+	seen := make(map[string]bool, len(files))
 	for i, file := range files {
-		fdp := protodesc.ToFileDescriptorProto(file)
-		fds.File[i] = fdp
+		fds.File[i] = protodesc.ToFileDescriptorProto(file)
+		seen[file.Path()] = true
+	}
 
-		if value, _ := protoregistry.GlobalFiles.FindFileByPath(fdp.GetName()); value != nil {
-			zerolog.Ctx(ctx).Warn().
-				Str("name", fdp.GetName()).
-				Str("path", file.Path()).
-				Msg("File already registered")
+	imports := &descriptorpb.FileDescriptorSet{}
+	for _, file := range files {
+		appendImports(imports, file, seen)
+	}
 
+	return fds, imports, nil
+}
+
+// appendImports adds the files imported by file that are not seen yet,
+// each after its own imports
+func appendImports(fds *descriptorpb.FileDescriptorSet, file protoreflect.FileDescriptor, seen map[string]bool) {
+	imports := file.Imports()
+	for i := range imports.Len() {
+		imported := imports.Get(i).FileDescriptor
+		if imported.IsPlaceholder() || seen[imported.Path()] {
 			continue
 		}
 
-		err := protoregistry.GlobalFiles.RegisterFile(file)
-		if err != nil {
-			return nil, errors.Wrapf(err, "error registering file %s", file.Path())
-		}
+		seen[imported.Path()] = true
+		appendImports(fds, imported, seen)
+		fds.File = append(fds.File, protodesc.ToFileDescriptorProto(imported))
 	}
-
-	return fds, nil
 }
 
-//nolint:cyclop
-func compile(ctx context.Context, configure *Configure) ([]*descriptorpb.FileDescriptorSet, error) {
+func compile(ctx context.Context, configure *Configure) (*Descriptors, error) {
 	capacity := len(configure.Descriptors())
 	if len(configure.Protos()) > 0 {
 		capacity++
 	}
 
-	results := make([]*descriptorpb.FileDescriptorSet, 0, capacity)
+	results := &Descriptors{
+		Sets:    make([]*descriptorpb.FileDescriptorSet, 0, capacity),
+		Imports: &descriptorpb.FileDescriptorSet{},
+	}
 
 	for _, descriptor := range configure.Descriptors() {
 		descriptorBytes, err := os.ReadFile(descriptor) //nolint:gosec
@@ -109,37 +127,17 @@ func compile(ctx context.Context, configure *Configure) ([]*descriptorpb.FileDes
 			return nil, errors.Wrapf(err, "failed to unmarshal descriptor: %s", descriptor)
 		}
 
-		for _, fd := range fds.GetFile() {
-			if value, _ := protoregistry.GlobalFiles.FindFileByPath(fd.GetName()); value != nil {
-				zerolog.Ctx(ctx).Warn().
-					Str("name", fd.GetName()).
-					Str("path", descriptor).
-					Msg("File already registered")
-
-				continue
-			}
-
-			fileDesc, err := protodesc.NewFile(fd, protoregistry.GlobalFiles)
-			if err != nil {
-				return nil, errors.Wrapf(err, "failed to create file descriptor: %s", descriptor)
-			}
-
-			err = protoregistry.GlobalFiles.RegisterFile(fileDesc)
-			if err != nil {
-				return nil, errors.Wrapf(err, "error registering file %s", descriptor)
-			}
-		}
-
-		results = append(results, fds)
+		results.Sets = append(results.Sets, fds)
 	}
 
 	if len(configure.Protos()) > 0 {
-		fds, err := createDescriptorSet(ctx, configure)
+		fds, imports, err := createDescriptorSet(ctx, configure)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create descriptor set")
 		}
 
-		results = append(results, fds)
+		results.Sets = append(results.Sets, fds)
+		results.Imports = imports
 	}
 
 	return results, nil
@@ -187,7 +185,16 @@ func findMinimalPaths(paths []string) []string {
 	return result
 }
 
-func Build(ctx context.Context, imports []string, paths []string) ([]*descriptorpb.FileDescriptorSet, error) {
+// Build compiles the protos and descriptor files found in paths. Descriptors are
+// shared between calls with unchanged inputs and must not be modified.
+func Build(ctx context.Context, imports []string, paths []string) (*Descriptors, error) {
+	return BuildWithCache(ctx, "", imports, paths)
+}
+
+// BuildWithCache is Build that also keeps the compiled descriptors in cacheDir,
+// reusing them in later processes while the inputs are unchanged. An empty
+// cacheDir keeps them in memory only.
+func BuildWithCache(ctx context.Context, cacheDir string, imports []string, paths []string) (*Descriptors, error) {
 	var err error
 
 	for i, importPath := range imports {
@@ -204,15 +211,20 @@ func Build(ctx context.Context, imports []string, paths []string) ([]*descriptor
 		}
 	}
 
-	configure, err := newConfigure(ctx, lo.Uniq(findMinimalPaths(imports)), lo.Uniq(paths))
+	configure, err := newConfigure(ctx, lo.Uniq(imports), lo.Uniq(paths))
 	if err != nil {
 		return nil, errors.Wrap(err, "create configuration")
 	}
 
-	return compile(ctx, configure)
+	return compileCached(ctx, configure, cacheDir)
 }
 
+// processor collects the files to compile and their import paths. The import
+// paths given by the caller are kept as given, like protoc's -I, even when
+// nested in one another; the directories of the files are merged into their
+// outermost directory.
 type processor struct {
+	explicit         []string
 	imports          []string
 	protos           []string
 	descriptors      []string
@@ -224,7 +236,7 @@ type processor struct {
 
 func newProcessor(initialImports []string) *processor {
 	return &processor{
-		imports:   initialImports,
+		explicit:  initialImports,
 		seenDirs:  make(map[string]bool),
 		seenFiles: make(map[string]bool),
 		allowedProtoExts: []string{
@@ -419,7 +431,7 @@ func (p *processor) addFile(ctx context.Context, filePath, fileType string) {
 		return
 	}
 
-	baseDir, _ := findPathByImports(fileAbs, p.imports)
+	baseDir, _ := findPathByImports(fileAbs, p.allImports())
 
 	relPath, err := filepath.Rel(baseDir, fileAbs)
 	if err != nil {
@@ -444,9 +456,14 @@ func (p *processor) addFile(ctx context.Context, filePath, fileType string) {
 	zerolog.Ctx(ctx).Debug().Str("type", fileType).Msg("File added successfully")
 }
 
+// allImports returns the import paths given by the caller, then those of the files
+func (p *processor) allImports() []string {
+	return lo.Uniq(append(slices.Clone(p.explicit), p.imports...))
+}
+
 func (p *processor) result() *Configure {
 	return &Configure{
-		imports:     lo.Uniq(p.imports),
+		imports:     p.allImports(),
 		protos:      lo.Uniq(p.protos),
 		descriptors: lo.Uniq(p.descriptors),
 	}
@@ -0,0 +1,30 @@
+package proto
+
+import (
+	"context"
+	"testing"
+)
+
+// An import path nested in a proto directory is a root of its own, as with protoc
+func TestBuildNestedImportPath(t *testing.T) {
+	for _, paths := range [][]string{
+		{"testdata/nested/svc.proto"},
+		{"testdata/nested"},
+	} {
+		descriptors, err := Build(context.Background(), []string{"testdata/nested/vendor"}, paths)
+		if err != nil {
+			t.Fatalf("Build(%v): %v", paths, err)
+		}
+
+		names := make(map[string]int)
+		for _, set := range append(descriptors.Sets, descriptors.Imports) {
+			for _, file := range set.GetFile() {
+				names[file.GetName()]++
+			}
+		}
+
+		if names["svc.proto"] != 1 || names["common/c.proto"] != 1 {
+			t.Fatalf("Build(%v) files = %v, want svc.proto and common/c.proto once", paths, names)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package gripmock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Call is a request received by the server
+type Call struct {
+	Service string
+	Method  string
+	// Data is the request message; for client-streaming calls it is the last one
+	Data map[string]interface{}
+	// Messages holds every request message of a client-streaming call
+	Messages []map[string]interface{}
+	Headers  map[string]interface{}
+	// StubID is the matched stub, or nil if no stub matched
+	StubID *uuid.UUID
+	Time   time.Time
+}
+
+// Matched returns true if a stub answered the call
+func (c Call) Matched() bool {
+	return c.StubID != nil
+}
+
+// TestingT is the subset of testing.TB used by assertions
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// CleanupT is the subset of testing.TB used to verify when a test ends
+type CleanupT interface {
+	TestingT
+	Cleanup(func())
+}
+
+// journal records every call received by a server
+type journal struct {
+	calls []Call
+	mu    sync.RWMutex
+}
+
+func newJournal() *journal {
+	return &journal{}
+}
+
+func (j *journal) record(call Call) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.calls = append(j.calls, call)
+}
+
+// list returns the calls of the method in the order they were received;
+// an empty service or method matches any
+func (j *journal) list(service, method string) []Call {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	calls := make([]Call, 0, len(j.calls))
+	for _, call := range j.calls {
+		if (service == "" || call.Service == service) && (method == "" || call.Method == method) {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// hits counts the calls answered by each stub
+func (j *journal) hits() map[uuid.UUID]int {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	hits := make(map[uuid.UUID]int)
+	for _, call := range j.calls {
+		if call.StubID != nil {
+			hits[*call.StubID]++
+		}
+	}
+
+	return hits
+}
+
+func (j *journal) clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.calls = nil
+}
+
+// assertCalledTimes fails the test unless the method received exactly n calls
+func assertCalledTimes(t TestingT, calls []Call, n int, service, method string) bool {
+	t.Helper()
+
+	if len(calls) != n {
+		t.Errorf("expected %d call(s) to %s, got %d", n, methodPath(service, method), len(calls))
+		return false
+	}
+
+	return true
+}
+
+// assertCalled fails the test unless the method received at least one call
+func assertCalled(t TestingT, calls []Call, service, method string) bool {
+	t.Helper()
+
+	if len(calls) == 0 {
+		t.Errorf("expected %s to be called, but it was not", methodPath(service, method))
+		return false
+	}
+
+	return true
+}
+
+func methodPath(service, method string) string {
+	return fmt.Sprintf("%s/%s", service, method)
+}
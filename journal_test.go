@@ -0,0 +1,109 @@
+package gripmock
+
+import (
+	"fmt"
+	"testing"
+)
+
+// recordingT records the failures reported to it instead of failing the test
+type recordingT struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+// finish runs the cleanups registered by the test, last first
+func (r *recordingT) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func TestVerifyUnmatchedCall(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := server.On("demo.Demo/Unary").
+		Matching(map[string]interface{}{"name": "known"}).
+		Returns(map[string]interface{}{"message": "hello"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	if _, err := callUnary(t, server, conn, "known"); err != nil {
+		t.Fatalf("Unary known: %v", err)
+	}
+
+	rt := &recordingT{}
+	if !server.Verify(rt) || len(rt.errors) != 0 {
+		t.Fatalf("Verify after a matched call reported %v", rt.errors)
+	}
+
+	if _, err := callUnary(t, server, conn, "unknown"); err == nil {
+		t.Fatal("Unary unknown succeeded without a stub")
+	}
+
+	rt = &recordingT{}
+	if server.Verify(rt) || len(rt.errors) != 1 {
+		t.Fatalf("Verify after an unmatched call reported %v, want one error", rt.errors)
+	}
+}
+
+func TestVerifyUnusedStub(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%v", strict), func(t *testing.T) {
+			var opts []Option
+			if strict {
+				opts = append(opts, WithStrictMode())
+			}
+			server, _ := startServer(t, []string{"testdata/demo"}, opts...)
+
+			if err := server.On("demo.Demo/Unary").Returns(map[string]interface{}{"message": "hello"}).Add(); err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+
+			rt := &recordingT{}
+			ok := server.Verify(rt)
+			if strict && (ok || len(rt.errors) != 1) {
+				t.Fatalf("strict Verify reported %v, want one error for the unused stub", rt.errors)
+			}
+			if !strict && (!ok || len(rt.errors) != 0) {
+				t.Fatalf("Verify reported %v, want no errors", rt.errors)
+			}
+		})
+	}
+}
+
+func TestTrack(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+	mocker := NewEmbeddedMocker(server)
+
+	if err := server.On("demo.Demo/Unary").Returns(map[string]interface{}{"message": "stale"}).Add(); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	rt := &recordingT{}
+	mocker.Track(rt)
+
+	if _, err := callUnary(t, server, conn, "any"); err == nil {
+		t.Fatal("Unary succeeded with a stub added before Track")
+	}
+
+	if len(rt.errors) != 0 {
+		t.Fatalf("errors reported before the test ended: %v", rt.errors)
+	}
+
+	rt.finish()
+	if len(rt.errors) != 1 {
+		t.Fatalf("Track reported %v, want one error for the unmatched call", rt.errors)
+	}
+}
@@ -0,0 +1,109 @@
+package gripmock
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// Latency describes how long a stub waits before answering.
+// Fixed wins over a uniform Min/Max range, which wins over a normal
+// distribution with Mean and StdDev.
+type Latency struct {
+	Fixed  time.Duration
+	Min    time.Duration
+	Max    time.Duration
+	Mean   time.Duration
+	StdDev time.Duration
+}
+
+// duration draws the delay of a single response
+func (l *Latency) duration() time.Duration {
+	switch {
+	case l == nil:
+		return 0
+	case l.Fixed > 0:
+		return l.Fixed
+	case l.Max > l.Min:
+		return l.Min + rand.N(l.Max-l.Min)
+	case l.Min > 0:
+		return l.Min
+	case l.StdDev > 0:
+		return max(0, l.Mean+time.Duration(rand.NormFloat64()*float64(l.StdDev)))
+	default:
+		return l.Mean
+	}
+}
+
+// wait sleeps for the latency, returning the status error of the
+// caller's context, such as DeadlineExceeded, if it ends first
+func wait(ctx context.Context, latency *Latency) error {
+	delay := latency.duration()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseLatency converts a delay given as time.Duration, a duration string
+// like "150ms", or a map with "min"/"max" or "mean"/"stddev" durations
+func parseLatency(value interface{}) (*Latency, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case *Latency:
+		return v, nil
+	case Latency:
+		return &v, nil
+	case map[string]interface{}:
+		latency := &Latency{}
+		fields := map[string]*time.Duration{
+			"fixed":  &latency.Fixed,
+			"min":    &latency.Min,
+			"max":    &latency.Max,
+			"mean":   &latency.Mean,
+			"stddev": &latency.StdDev,
+		}
+		for key, raw := range v {
+			field, ok := fields[key]
+			if !ok {
+				return nil, fmt.Errorf("unknown delay key %q", key)
+			}
+			duration, err := parseDuration(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid delay %s: %w", key, err)
+			}
+			*field = duration
+		}
+		return latency, nil
+	default:
+		duration, err := parseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		return &Latency{Fixed: duration}, nil
+	}
+}
+
+func parseDuration(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		return time.ParseDuration(v)
+	default:
+		return 0, fmt.Errorf("invalid duration type %T", value)
+	}
+}
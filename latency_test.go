@@ -0,0 +1,74 @@
+package gripmock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDelayedResponse(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := server.On("demo.Demo/Unary").
+		Matching(map[string]interface{}{"name": "slow"}).
+		Returns(map[string]interface{}{"message": "late"}).
+		Delay(100 * time.Millisecond).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	start := time.Now()
+	message, err := callUnary(t, server, conn, "slow")
+	if err != nil {
+		t.Fatalf("Unary: %v", err)
+	}
+	if message != "late" {
+		t.Fatalf("message = %q, want late", message)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("answered after %v, want the 100ms delay", elapsed)
+	}
+}
+
+func TestDelayExceedsDeadline(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	for _, method := range []string{"Unary", "ServerStream"} {
+		err := server.On("demo.Demo/" + method).
+			Returns(map[string]interface{}{"message": "too late"}).
+			Delay(time.Minute).
+			Add()
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(testContext(t), 50*time.Millisecond)
+	defer cancel()
+
+	req := demoMessage(t, server, "demo.Request", map[string]interface{}{"name": "any"})
+	resp := demoMessage(t, server, "demo.Response", map[string]interface{}{})
+	if err := conn.Invoke(ctx, "/demo.Demo/Unary", req, resp); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("Unary: %v, want DeadlineExceeded", err)
+	}
+
+	ctx, cancel = context.WithTimeout(testContext(t), 50*time.Millisecond)
+	defer cancel()
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "/demo.Demo/ServerStream")
+	if err != nil {
+		t.Fatalf("NewStream: %v", err)
+	}
+	sendRequest(t, server, stream, "any")
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+	if _, err := recvMessage(t, server, stream); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("ServerStream: %v, want DeadlineExceeded", err)
+	}
+}
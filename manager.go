@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -12,22 +13,32 @@ import (
 
 // MultiServerManager manages multiple embedded gripmock servers
 type MultiServerManager struct {
-	servers map[int]*EmbeddedMocker // map[port]*EmbeddedMocker
+	servers []*EmbeddedMocker
 	mu      sync.RWMutex
 }
 
 // ServerConfig represents configuration for a single gripmock server
 type ServerConfig struct {
-	Port       int
-	ProtoDir   string
-	Identifier string // optional identifier for logging
+	Port        int    // 0 picks a free port, see MultiServerManager.GetServerPorts
+	Address     string // optional listen address replacing Port, e.g. unix:///tmp/mock.sock
+	AdminPort   int    // optional port for the admin REST API, disabled if 0; see WithAdminPort for a free port
+	ProtoDir    string
+	ImportPaths []string // optional directories to resolve imports from, e.g. vendored googleapis
+	Identifier  string   // optional identifier for logging
+	Options     []Option // optional server options, e.g. WithStrictMode()
+}
+
+// listenTarget describes where the configured server listens, for error messages
+func (c ServerConfig) listenTarget() string {
+	if c.Address != "" {
+		return c.Address
+	}
+	return fmt.Sprintf("port %d", c.Port)
 }
 
 // NewMultiServerManager creates a new manager for multiple gripmock servers
 func NewMultiServerManager() *MultiServerManager {
-	return &MultiServerManager{
-		servers: make(map[int]*EmbeddedMocker),
-	}
+	return &MultiServerManager{}
 }
 
 // StartServers starts multiple gripmock servers with the given configurations
@@ -35,35 +46,54 @@ func (m *MultiServerManager) StartServers(ctx context.Context, configs []ServerC
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// Servers sharing a proto directory share its files and compiled descriptors
+	discovered := make(map[string][]string)
+
 	for _, config := range configs {
 		// Discover proto files from directory
-		protoFiles, err := discoverProtoFiles(config.ProtoDir)
-		if err != nil {
-			return fmt.Errorf("failed to discover proto files in %s: %w", config.ProtoDir, err)
+		protoFiles, ok := discovered[config.ProtoDir]
+		if !ok {
+			var err error
+			protoFiles, err = discoverProtoFiles(config.ProtoDir)
+			if err != nil {
+				return fmt.Errorf("failed to discover proto files in %s: %w", config.ProtoDir, err)
+			}
+			discovered[config.ProtoDir] = protoFiles
 		}
 
 		// Create server
-		server, err := NewServer(config.Port, protoFiles)
+		opts := append([]Option{}, config.Options...)
+		if config.Address != "" {
+			opts = append(opts, WithAddress(config.Address))
+		}
+		if config.AdminPort > 0 {
+			opts = append(opts, WithAdminPort(config.AdminPort))
+		}
+		if len(config.ImportPaths) > 0 {
+			opts = append(opts, WithImportPaths(config.ImportPaths...))
+		}
+
+		server, err := NewServer(config.Port, protoFiles, opts...)
 		if err != nil {
-			return fmt.Errorf("failed to create server on port %d: %w", config.Port, err)
+			return fmt.Errorf("failed to create server on %s: %w", config.listenTarget(), err)
 		}
 
 		// Start server
 		if err := server.Start(ctx); err != nil {
-			return fmt.Errorf("failed to start server on port %d: %w", config.Port, err)
+			return fmt.Errorf("failed to start server on %s: %w", config.listenTarget(), err)
 		}
 
 		// Wait for server to be ready
 		if err := server.WaitForReady(5 * time.Second); err != nil {
 			server.Stop()
-			return fmt.Errorf("server on port %d not ready: %w", config.Port, err)
+			return fmt.Errorf("server on %s not ready: %w", config.listenTarget(), err)
 		}
 
 		// Create embedded mocker
 		mocker := NewEmbeddedMocker(server)
-		m.servers[config.Port] = mocker
+		m.servers = append(m.servers, mocker)
 
-		fmt.Printf("Started gripmock server on port %d with %d proto files\n", config.Port, len(protoFiles))
+		fmt.Printf("Started gripmock server on %s with %d proto files\n", server.Addr(), len(protoFiles))
 	}
 
 	return nil
@@ -74,11 +104,12 @@ func (m *MultiServerManager) StopAll() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for port, mocker := range m.servers {
+	for _, mocker := range m.servers {
+		addr := mocker.GetServer().Addr()
 		mocker.GetServer().Stop()
-		fmt.Printf("Stopped gripmock server on port %d\n", port)
+		fmt.Printf("Stopped gripmock server on %s\n", addr)
 	}
-	m.servers = make(map[int]*EmbeddedMocker)
+	m.servers = nil
 }
 
 // AddStub adds a stub to all running servers
@@ -91,9 +122,9 @@ func (m *MultiServerManager) AddStub(service, method string, input, output inter
 	}
 
 	var lastErr error
-	for port, mocker := range m.servers {
+	for _, mocker := range m.servers {
 		if err := mocker.AddStub(service, method, input, output); err != nil {
-			lastErr = fmt.Errorf("failed to add stub to server on port %d: %w", port, err)
+			lastErr = fmt.Errorf("failed to add stub to server on %s: %w", mocker.GetServer().Addr(), err)
 		}
 	}
 
@@ -110,14 +141,57 @@ func (m *MultiServerManager) Clear() {
 	}
 }
 
+// SetServingStatus sets the health status of a service on all servers
+func (m *MultiServerManager) SetServingStatus(service string, status ServingStatus) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, mocker := range m.servers {
+		mocker.SetServingStatus(service, status)
+	}
+}
+
+// Calls returns the calls received by all servers, ordered by arrival time
+func (m *MultiServerManager) Calls(service, method string) []Call {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var calls []Call
+	for _, mocker := range m.servers {
+		calls = append(calls, mocker.Calls(service, method)...)
+	}
+
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].Time.Before(calls[j].Time)
+	})
+
+	return calls
+}
+
+// Verify verifies every server, failing the test for all their leftovers
+func (m *MultiServerManager) Verify(t TestingT) bool {
+	t.Helper()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ok := true
+	for _, mocker := range m.servers {
+		if !mocker.Verify(t) {
+			ok = false
+		}
+	}
+	return ok
+}
+
 // GetServerPorts returns all active server ports
 func (m *MultiServerManager) GetServerPorts() []int {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	ports := make([]int, 0, len(m.servers))
-	for port := range m.servers {
-		ports = append(ports, port)
+	for _, mocker := range m.servers {
+		ports = append(ports, mocker.GetServer().GetPort())
 	}
 	return ports
 }
@@ -127,8 +201,30 @@ func (m *MultiServerManager) GetServer(port int) (*EmbeddedMocker, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	mocker, exists := m.servers[port]
-	return mocker, exists
+	for _, mocker := range m.servers {
+		if mocker.GetServer().GetPort() == port {
+			return mocker, true
+		}
+	}
+	return nil, false
+}
+
+// GetServerByAddress returns the embedded mocker listening on a specific address,
+// given as configured (e.g. unix:///tmp/mock.sock) or as reported by Server.Addr
+func (m *MultiServerManager) GetServerByAddress(address string) (*EmbeddedMocker, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, mocker := range m.servers {
+		server := mocker.GetServer()
+		if server.address == address {
+			return mocker, true
+		}
+		if addr := server.Addr(); addr != nil && addr.String() == address {
+			return mocker, true
+		}
+	}
+	return nil, false
 }
 
 // IsRunning returns true if all servers are running
@@ -0,0 +1,76 @@
+package gripmock
+
+import (
+	"github.com/bavix/features"
+	"github.com/google/uuid"
+	"github.com/gripmock/stuber"
+)
+
+// searchStubs runs stuber's matching over the given subset of stubs and
+// returns the original stub that answers the query, or nil
+func searchStubs(stubs []*stuber.Stub, query stuber.Query) *stuber.Stub {
+	if len(stubs) == 0 {
+		return nil
+	}
+
+	originals := make(map[uuid.UUID]*stuber.Stub, len(stubs))
+	copies := make([]*stuber.Stub, 0, len(stubs))
+	for _, stub := range stubs {
+		probe := *stub
+		originals[probe.ID] = stub
+		copies = append(copies, &probe)
+	}
+
+	store := stuber.NewBudgerigar(features.New())
+	store.PutMany(copies...)
+
+	result, err := store.FindByQuery(query)
+	if err != nil || result.Found() == nil {
+		return nil
+	}
+
+	return originals[result.Found().ID]
+}
+
+// matchInput reports whether a single message satisfies the input rules
+// using the service, method and header rules of the given stub
+func matchInput(stub *stuber.Stub, input stuber.InputData, headers, data map[string]interface{}) bool {
+	probe := *stub
+	probe.Input = input
+
+	return searchStubs([]*stuber.Stub{&probe}, stuber.Query{
+		Service: stub.Service,
+		Method:  stub.Method,
+		Headers: headers,
+		Data:    data,
+	}) != nil
+}
+
+// matchAlternatives returns a copy of the stub taking the first of the inputs
+// that the message satisfies, or nil if it satisfies none
+func matchAlternatives(stub *stuber.Stub, inputs []stuber.InputData, headers, data map[string]interface{}) *stuber.Stub {
+	for _, input := range inputs {
+		if matchInput(stub, input, headers, data) {
+			probe := *stub
+			probe.Input = input
+			return &probe
+		}
+	}
+
+	return nil
+}
+
+// matchSequence reports whether the messages match the inputs one by one, in order
+func matchSequence(stub *stuber.Stub, inputs []stuber.InputData, headers map[string]interface{}, messages []map[string]interface{}) bool {
+	if len(inputs) != len(messages) {
+		return false
+	}
+
+	for i, input := range inputs {
+		if !matchInput(stub, input, headers, messages[i]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,57 @@
+package gripmock
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/gripmock/stuber"
+	"google.golang.org/grpc/metadata"
+)
+
+// binarySuffix marks metadata keys whose values are binary
+const binarySuffix = "-bin"
+
+// stubMetadata returns the response headers and trailers of the stub
+func stubMetadata(stub *stuber.Stub, ext *StubExtension) (metadata.MD, metadata.MD, error) {
+	header, err := newMetadata(stub.Output.Headers)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid headers: %w", err)
+	}
+
+	trailer, err := newMetadata(ext.Trailers)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid trailers: %w", err)
+	}
+
+	return header, trailer, nil
+}
+
+// newMetadata converts stub metadata values into gRPC metadata.
+// Values of "-bin" keys are base64-decoded, gRPC encodes them on the wire.
+func newMetadata(values map[string]string) (metadata.MD, error) {
+	md := metadata.MD{}
+	for key, value := range values {
+		key = strings.ToLower(key)
+
+		if strings.HasSuffix(key, binarySuffix) {
+			decoded, err := decodeBinaryValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", key, err)
+			}
+			value = string(decoded)
+		}
+
+		md.Append(key, value)
+	}
+
+	return md, nil
+}
+
+func decodeBinaryValue(value string) ([]byte, error) {
+	if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+
+	return base64.RawStdEncoding.DecodeString(value)
+}
@@ -0,0 +1,92 @@
+package gripmock
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// binaryValue is the raw value sent base64-encoded in "-bin" stub metadata
+const binaryValue = "\x00\x01raw\xff"
+
+func addMetadataStub(t *testing.T, server *Server, method string) {
+	t.Helper()
+
+	builder := server.On("demo.Demo/"+method).
+		WithResponseHeader("x-request-id", "42").
+		WithResponseHeader("trace-bin", base64.StdEncoding.EncodeToString([]byte(binaryValue))).
+		WithTrailer("x-checksum", "abc").
+		WithTrailer("state-bin", base64.StdEncoding.EncodeToString([]byte(binaryValue)))
+	if method == "ServerStream" {
+		builder = builder.ReturnsStream(map[string]interface{}{"message": "one"})
+	} else {
+		builder = builder.Returns(map[string]interface{}{"message": "one"})
+	}
+
+	if err := builder.Add(); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+}
+
+func checkMetadata(t *testing.T, header, trailer metadata.MD) {
+	t.Helper()
+
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "42" {
+		t.Errorf("header x-request-id = %q, want 42", got)
+	}
+	if got := header.Get("trace-bin"); len(got) != 1 || got[0] != binaryValue {
+		t.Errorf("header trace-bin = %q, want the decoded bytes %q", got, binaryValue)
+	}
+	if got := trailer.Get("x-checksum"); len(got) != 1 || got[0] != "abc" {
+		t.Errorf("trailer x-checksum = %q, want abc", got)
+	}
+	if got := trailer.Get("state-bin"); len(got) != 1 || got[0] != binaryValue {
+		t.Errorf("trailer state-bin = %q, want the decoded bytes %q", got, binaryValue)
+	}
+}
+
+func TestUnaryMetadata(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+	addMetadataStub(t, server, "Unary")
+
+	var header, trailer metadata.MD
+	req := demoMessage(t, server, "demo.Request", map[string]interface{}{"name": "any"})
+	resp := demoMessage(t, server, "demo.Response", map[string]interface{}{})
+	if err := conn.Invoke(testContext(t), "/demo.Demo/Unary", req, resp, grpc.Header(&header), grpc.Trailer(&trailer)); err != nil {
+		t.Fatalf("Unary: %v", err)
+	}
+
+	checkMetadata(t, header, trailer)
+}
+
+func TestServerStreamMetadata(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+	addMetadataStub(t, server, "ServerStream")
+
+	stream := openDemoStream(t, conn, "ServerStream", false, true)
+	sendRequest(t, server, stream, "any")
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+
+	header, err := stream.Header()
+	if err != nil {
+		t.Fatalf("Header: %v", err)
+	}
+
+	for {
+		_, err := recvMessage(t, server, stream)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+	}
+
+	checkMetadata(t, header, stream.Trailer())
+}
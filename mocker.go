@@ -2,8 +2,13 @@ package gripmock
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -12,17 +17,23 @@ import (
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
-	"google.golang.org/protobuf/reflect/protoregistry"
 	"google.golang.org/protobuf/types/dynamicpb"
 
 	"github.com/goccy/go-json"
 	"github.com/gripmock/stuber"
+	"github.com/rs/zerolog"
 )
 
 type SimpleMocker struct {
 	budgerigar      *stuber.Budgerigar
+	extensions      *extensionStore
+	journal         *journal
+	registry        *registry
+	logger          zerolog.Logger
 	fullServiceName string
 	methodName      string
+	serverStreams   bool
+	clientStreams   bool
 }
 
 func (m *SimpleMocker) unaryHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
@@ -37,25 +48,44 @@ func (m *SimpleMocker) unaryHandler(srv interface{}, ctx context.Context, dec fu
 		return nil, err
 	}
 
-	query := stuber.Query{
-		Service: m.fullServiceName,
-		Method:  m.methodName,
-		Data:    m.convertToMap(req),
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return nil, status.Errorf(codes.Internal, "unexpected request type %T", req)
+		}
+		return m.respond(ctx, msg, outputDesc)
+	}
+
+	// Interceptors set with WithUnaryInterceptors wrap the stub lookup
+	if interceptor == nil {
+		return handler(ctx, req)
 	}
 
-	// Add headers if present
-	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		query.Headers = m.processHeaders(md)
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: fmt.Sprintf("/%s/%s", m.fullServiceName, m.methodName),
 	}
 
-	result, err := m.budgerigar.FindByQuery(query)
+	return interceptor(ctx, req, info, handler)
+}
+
+// respond answers a unary request with the matching stub
+func (m *SimpleMocker) respond(ctx context.Context, req proto.Message, outputDesc protoreflect.MessageDescriptor) (interface{}, error) {
+	found, ext, err := m.findStub(ctx, req)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to find stub: %v", err)
+		return nil, err
 	}
 
-	found := result.Found()
-	if found == nil {
-		return nil, status.Errorf(codes.NotFound, "no stub found for service %s, method %s", m.fullServiceName, m.methodName)
+	if err := m.setUnaryMetadata(ctx, found, ext); err != nil {
+		return nil, err
+	}
+
+	if err := wait(ctx, ext.Delay); err != nil {
+		return nil, err
+	}
+
+	if err := stubError(found, ext, m.registry); err != nil {
+		return nil, err
 	}
 
 	// Convert response to dynamic message
@@ -68,26 +98,509 @@ func (m *SimpleMocker) unaryHandler(srv interface{}, ctx context.Context, dec fu
 }
 
 func (m *SimpleMocker) streamHandler(srv interface{}, stream grpc.ServerStream) error {
-	return status.Errorf(codes.Unimplemented, "streaming not implemented in simplified version")
+	if m.serverStreams && !m.clientStreams {
+		return m.serverStreamHandler(stream)
+	}
+
+	if m.clientStreams && !m.serverStreams {
+		return m.clientStreamHandler(stream)
+	}
+
+	return m.bidiStreamHandler(stream)
 }
 
-func (m *SimpleMocker) getMessageDescriptors() (protoreflect.MessageDescriptor, protoreflect.MessageDescriptor, error) {
-	// Try to resolve service and method descriptors from the global registry
-	serviceDesc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(m.fullServiceName))
+// serverStreamHandler reads the single request of a server-streaming call and
+// sends every message of the matched stub's stream. Stubs without a stream
+// answer with their data as the only message.
+func (m *SimpleMocker) serverStreamHandler(stream grpc.ServerStream) error {
+	inputDesc, outputDesc, err := m.getMessageDescriptors()
+	if err != nil {
+		return err
+	}
+
+	req := dynamicpb.NewMessage(inputDesc)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+
+	found, ext, err := m.findStub(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	if err := m.setStreamMetadata(stream, found, ext, false); err != nil {
+		return err
+	}
+
+	return m.sendStubMessages(stream, found, ext, outputDesc)
+}
+
+// clientStreamHandler collects every request of a client-streaming call until
+// the client closes its side, then replies with the matched stub's data.
+func (m *SimpleMocker) clientStreamHandler(stream grpc.ServerStream) error {
+	inputDesc, outputDesc, err := m.getMessageDescriptors()
+	if err != nil {
+		return err
+	}
+
+	var messages []map[string]interface{}
+	for {
+		req := dynamicpb.NewMessage(inputDesc)
+		err := stream.RecvMsg(req)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		messages = append(messages, m.convertToMap(req))
+	}
+
+	found, ext, err := m.findSequenceStub(stream.Context(), messages)
+	if err != nil {
+		return err
+	}
+
+	if err := m.setStreamMetadata(stream, found, ext, false); err != nil {
+		return err
+	}
+
+	if err := wait(stream.Context(), ext.Delay); err != nil {
+		return err
+	}
+
+	if err := stubError(found, ext, m.registry); err != nil {
+		return err
+	}
+
+	outputMsg, err := m.newOutputMessage(found.Output.Data, outputDesc)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to create response: %v", err)
+	}
+
+	return stream.SendMsg(outputMsg)
+}
+
+// bidiStreamHandler answers every inbound message of a bidirectional call as
+// soon as it arrives. A conversation stub whose first step matches the first
+// message scripts the whole call; otherwise each message is matched on its own,
+// by any of the inputs of stubs with inputs, and the matched stub's messages
+// are sent back.
+func (m *SimpleMocker) bidiStreamHandler(stream grpc.ServerStream) error {
+	inputDesc, outputDesc, err := m.getMessageDescriptors()
 	if err != nil {
-		// If not found in registry, create basic message descriptors
-		return nil, nil, fmt.Errorf("service descriptor not found: %v", err)
+		return err
+	}
+
+	headers := m.incomingHeaders(stream.Context())
+
+	var (
+		conversation    *stuber.Stub
+		conversationExt *StubExtension
+	)
+	for step := 0; ; step++ {
+		req := dynamicpb.NewMessage(inputDesc)
+		err := stream.RecvMsg(req)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data := m.convertToMap(req)
+
+		if step == 0 {
+			conversationStubs, _ := m.splitStubs(func(ext *StubExtension) bool {
+				return len(ext.Conversation) > 0
+			})
+			for _, stub := range conversationStubs {
+				if !matchInput(stub, m.extensions.get(stub.ID).Conversation[0].Input, headers, data) {
+					continue
+				}
+				if ext, ok := m.claim(stub); ok {
+					conversation, conversationExt = stub, ext
+					break
+				}
+			}
+
+			if conversation != nil {
+				if err := m.setStreamMetadata(stream, conversation, conversationExt, false); err != nil {
+					return err
+				}
+			}
+		}
+
+		if conversation != nil {
+			ext := conversationExt
+			exchanges := ext.Conversation
+			if step >= len(exchanges) {
+				m.record(headers, data, nil, nil)
+				return status.Errorf(codes.NotFound, "conversation for service %s, method %s has no step for message %d", m.fullServiceName, m.methodName, step+1)
+			}
+			if !matchInput(conversation, exchanges[step].Input, headers, data) {
+				m.record(headers, data, nil, nil)
+				return status.Errorf(codes.NotFound, "message %d does not match conversation step for service %s, method %s", step+1, m.fullServiceName, m.methodName)
+			}
+
+			m.record(headers, data, nil, conversation)
+
+			if err := wait(stream.Context(), ext.Delay); err != nil {
+				return err
+			}
+
+			outputMsg, err := m.newOutputMessage(exchanges[step].Output, outputDesc)
+			if err != nil {
+				return status.Errorf(codes.Internal, "failed to create response: %v", err)
+			}
+			if err := stream.SendMsg(outputMsg); err != nil {
+				return err
+			}
+			continue
+		}
+
+		query := stuber.Query{
+			Service: m.fullServiceName,
+			Method:  m.methodName,
+			Headers: headers,
+			Data:    data,
+		}
+
+		// Each message is matched against the stubs stored when it arrives;
+		// stubs used up since they were read are skipped for the next match
+		_, messageStubs := m.splitStubs(func(ext *StubExtension) bool {
+			return len(ext.Conversation) > 0
+		})
+		alternativeStubs, _ := m.splitStubs(alternatives)
+
+		var (
+			found *stuber.Stub
+			ext   *StubExtension
+		)
+		for {
+			found = m.searchAlternatives(alternativeStubs, messageStubs, query)
+			if found == nil {
+				break
+			}
+
+			var ok bool
+			if ext, ok = m.claim(found); ok {
+				break
+			}
+			alternativeStubs = withoutStub(alternativeStubs, found)
+			messageStubs = withoutStub(messageStubs, found)
+		}
+		m.record(headers, data, nil, found)
+		if found == nil {
+			return m.notFound(headers, data)
+		}
+
+		if err := m.setStreamMetadata(stream, found, ext, step > 0); err != nil {
+			return err
+		}
+
+		if err := m.sendStubMessages(stream, found, ext, outputDesc); err != nil {
+			return err
+		}
+	}
+}
+
+// sendStubMessages waits for the stub's delay, then sends every message of
+// its stream, or its data as the only message when the stub has no stream.
+// Stubs with an error end the call with it instead.
+func (m *SimpleMocker) sendStubMessages(stream grpc.ServerStream, stub *stuber.Stub, ext *StubExtension, outputDesc protoreflect.MessageDescriptor) error {
+	if err := wait(stream.Context(), ext.Delay); err != nil {
+		return err
+	}
+
+	if err := stubError(stub, ext, m.registry); err != nil {
+		return err
 	}
 
-	service, ok := serviceDesc.(protoreflect.ServiceDescriptor)
+	messages := ext.Stream
+	if len(messages) == 0 {
+		messages = []map[string]interface{}{stub.Output.Data}
+	}
+
+	for _, data := range messages {
+		outputMsg, err := m.newOutputMessage(data, outputDesc)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to create response: %v", err)
+		}
+
+		if err := stream.SendMsg(outputMsg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setUnaryMetadata sends the stub's headers and trailers with a unary response
+func (m *SimpleMocker) setUnaryMetadata(ctx context.Context, stub *stuber.Stub, ext *StubExtension) error {
+	header, trailer, err := stubMetadata(stub, ext)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to create metadata: %v", err)
+	}
+
+	if err := grpc.SetHeader(ctx, header); err != nil {
+		return status.Errorf(codes.Internal, "failed to set headers: %v", err)
+	}
+
+	if err := grpc.SetTrailer(ctx, trailer); err != nil {
+		return status.Errorf(codes.Internal, "failed to set trailers: %v", err)
+	}
+
+	return nil
+}
+
+// setStreamMetadata attaches the stub's headers and trailers to the stream.
+// Headers can only be set until the first message is sent, later ones are skipped.
+func (m *SimpleMocker) setStreamMetadata(stream grpc.ServerStream, stub *stuber.Stub, ext *StubExtension, headersSent bool) error {
+	header, trailer, err := stubMetadata(stub, ext)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to create metadata: %v", err)
+	}
+
+	if !headersSent {
+		if err := stream.SetHeader(header); err != nil {
+			return status.Errorf(codes.Internal, "failed to set headers: %v", err)
+		}
+	}
+
+	stream.SetTrailer(trailer)
+
+	return nil
+}
+
+// findStub looks up the stub matching the request and the incoming metadata,
+// returning it with its extension. Stubs with inputs match if any of them
+// matches the request.
+func (m *SimpleMocker) findStub(ctx context.Context, req proto.Message) (*stuber.Stub, *StubExtension, error) {
+	query := stuber.Query{
+		Service: m.fullServiceName,
+		Method:  m.methodName,
+		Headers: m.incomingHeaders(ctx),
+		Data:    m.convertToMap(req),
+	}
+
+	var found *stuber.Stub
+	alternativeStubs, plainStubs := m.splitStubs(func(ext *StubExtension) bool {
+		return len(ext.Inputs) > 0
+	})
+	if len(alternativeStubs) == 0 {
+		result, err := m.budgerigar.FindByQuery(query)
+		if err != nil {
+			m.record(query.Headers, query.Data, nil, nil)
+			return nil, nil, status.Errorf(codes.Internal, "failed to find stub: %v", err)
+		}
+		found = result.Found()
+	} else {
+		found = m.searchAlternatives(alternativeStubs, plainStubs, query)
+	}
+
+	found, ext := m.claimFound(found)
+	m.record(query.Headers, query.Data, nil, found)
+	if found == nil {
+		return nil, nil, m.notFound(query.Headers, query.Data)
+	}
+
+	return found, ext, nil
+}
+
+// findSequenceStub matches the whole request sequence of a client stream.
+// Stubs with inputs must match every message in order and are tried first,
+// by priority; the other stubs are matched against the last message.
+func (m *SimpleMocker) findSequenceStub(ctx context.Context, messages []map[string]interface{}) (*stuber.Stub, *StubExtension, error) {
+	headers := m.incomingHeaders(ctx)
+	sequenceStubs, lastMessageStubs := m.splitStubs(func(ext *StubExtension) bool {
+		return len(ext.Inputs) > 0
+	})
+
+	last := map[string]interface{}{}
+	if len(messages) > 0 {
+		last = messages[len(messages)-1]
+	}
+
+	for _, stub := range sequenceStubs {
+		if !matchSequence(stub, m.extensions.get(stub.ID).Inputs, headers, messages) {
+			continue
+		}
+		if ext, ok := m.claim(stub); ok {
+			m.record(headers, last, messages, stub)
+			return stub, ext, nil
+		}
+	}
+
+	found := searchStubs(lastMessageStubs, stuber.Query{
+		Service: m.fullServiceName,
+		Method:  m.methodName,
+		Headers: headers,
+		Data:    last,
+	})
+	found, ext := m.claimFound(found)
+	m.record(headers, last, messages, found)
+	if found == nil {
+		return nil, nil, m.notFound(headers, last)
+	}
+
+	return found, ext, nil
+}
+
+// notFound builds the NotFound error of an unmatched request, listing the
+// closest stubs of the method and where the request differs from them.
+// Stubs with inputs are compared by each input, unless the inputs are the
+// message sequence of a client-streaming call.
+func (m *SimpleMocker) notFound(headers, data map[string]interface{}) error {
+	alternativeStubs, candidates := m.splitStubs(alternatives)
+	if !m.clientStreams || m.serverStreams {
+		for _, stub := range alternativeStubs {
+			for _, input := range m.extensions.get(stub.ID).Inputs {
+				probe := *stub
+				probe.Input = input
+				candidates = append(candidates, &probe)
+			}
+		}
+	}
+	misses := findNearMisses(candidates, headers, data)
+
+	for _, miss := range misses {
+		m.logger.Debug().
+			Str("service", m.fullServiceName).
+			Str("method", m.methodName).
+			Str("stub", miss.stub.ID.String()).
+			Strs("diff", miss.diffs).
+			Msg("Stub did not match")
+	}
+
+	return status.Errorf(codes.NotFound, "no stub found for service %s, method %s%s", m.fullServiceName, m.methodName, formatNearMisses(misses))
+}
+
+// record adds the call to the journal; stub is nil when no stub matched
+func (m *SimpleMocker) record(headers, data map[string]interface{}, messages []map[string]interface{}, stub *stuber.Stub) {
+	call := Call{
+		Service:  m.fullServiceName,
+		Method:   m.methodName,
+		Data:     data,
+		Messages: messages,
+		Headers:  headers,
+		Time:     time.Now(),
+	}
+	if stub != nil {
+		id := stub.ID
+		call.StubID = &id
+	}
+
+	m.journal.record(call)
+}
+
+// claim takes one of the calls the stub may answer and returns its extension,
+// removing the stub once its Times limit is used up; it returns false if the
+// stub was already removed
+func (m *SimpleMocker) claim(stub *stuber.Stub) (*StubExtension, bool) {
+	return m.extensions.claim(stub.ID, m.budgerigar)
+}
+
+// claimFound claims the found stub, if any, returning nil for both when
+// there is none or it can no longer be claimed
+func (m *SimpleMocker) claimFound(found *stuber.Stub) (*stuber.Stub, *StubExtension) {
+	if found == nil {
+		return nil, nil
+	}
+
+	ext, ok := m.claim(found)
 	if !ok {
-		return nil, nil, fmt.Errorf("not a service descriptor: %s", m.fullServiceName)
+		return nil, nil
+	}
+
+	return found, ext
+}
+
+// withoutStub returns the stubs other than the given one
+func withoutStub(stubs []*stuber.Stub, stub *stuber.Stub) []*stuber.Stub {
+	return slices.DeleteFunc(slices.Clone(stubs), func(s *stuber.Stub) bool {
+		return s.ID == stub.ID
+	})
+}
+
+// searchAlternatives matches the query against the plain stubs and the stubs
+// with alternative inputs, each taking the first input the request satisfies
+func (m *SimpleMocker) searchAlternatives(alternativeStubs, plainStubs []*stuber.Stub, query stuber.Query) *stuber.Stub {
+	candidates := append([]*stuber.Stub{}, plainStubs...)
+	for _, stub := range alternativeStubs {
+		if probe := matchAlternatives(stub, m.extensions.get(stub.ID).Inputs, query.Headers, query.Data); probe != nil {
+			candidates = append(candidates, probe)
+		}
+	}
+
+	found := searchStubs(candidates, query)
+	if found == nil {
+		return nil
 	}
 
-	// Find the method
-	methodDesc := service.Methods().ByName(protoreflect.Name(m.methodName))
-	if methodDesc == nil {
-		return nil, nil, fmt.Errorf("method not found: %s", m.methodName)
+	// Return the stub rather than the probe of its matching input
+	for _, stub := range alternativeStubs {
+		if stub.ID == found.ID {
+			return stub
+		}
+	}
+
+	return found
+}
+
+// alternatives selects the stubs matched by any of their inputs, rather than
+// by a conversation
+func alternatives(ext *StubExtension) bool {
+	return len(ext.Inputs) > 0 && len(ext.Conversation) == 0
+}
+
+// splitStubs returns the stubs of this method selected by the extension
+// predicate, ordered by priority, and the plain stubs matched by their input
+func (m *SimpleMocker) splitStubs(selected func(*StubExtension) bool) ([]*stuber.Stub, []*stuber.Stub) {
+	var scripted, plain []*stuber.Stub
+	for _, stub := range m.budgerigar.All() {
+		if stub.Service != m.fullServiceName || stub.Method != m.methodName {
+			continue
+		}
+
+		ext := m.extensions.get(stub.ID)
+		switch {
+		case selected(ext):
+			scripted = append(scripted, stub)
+		case !ext.scripted():
+			plain = append(plain, stub)
+		}
+	}
+
+	sort.SliceStable(scripted, func(i, j int) bool {
+		return scripted[i].Priority > scripted[j].Priority
+	})
+
+	return scripted, plain
+}
+
+// incomingHeaders returns the matchable request headers, including the peer
+// certificate pseudo-headers of mutual TLS calls, if present
+func (m *SimpleMocker) incomingHeaders(ctx context.Context) map[string]interface{} {
+	md, _ := metadata.FromIncomingContext(ctx)
+	headers := m.processHeaders(md)
+
+	// Verified client certificates are matched through pseudo-headers
+	if peer := peerHeaders(ctx); peer != nil {
+		if headers == nil {
+			headers = make(map[string]interface{}, len(peer))
+		}
+		for k, v := range peer {
+			headers[k] = v
+		}
+	}
+
+	return headers
+}
+
+func (m *SimpleMocker) getMessageDescriptors() (protoreflect.MessageDescriptor, protoreflect.MessageDescriptor, error) {
+	methodDesc, err := m.registry.findMethod(m.fullServiceName, m.methodName)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	inputDesc := methodDesc.Input()
@@ -206,7 +719,7 @@ func (m *SimpleMocker) newOutputMessage(data map[string]interface{}, outputDesc
 	}
 
 	msg := dynamicpb.NewMessage(outputDesc)
-	err = protojson.Unmarshal(jsonData, msg)
+	err = protojson.UnmarshalOptions{Resolver: m.registry}.Unmarshal(jsonData, msg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal JSON into dynamic message: %w", err)
 	}
@@ -0,0 +1,516 @@
+package gripmock
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/gripmock/stuber"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// demoMessage builds a message of the demo protos from its JSON fields
+func demoMessage(t *testing.T, server *Server, name string, fields map[string]interface{}) *dynamicpb.Message {
+	t.Helper()
+
+	mt, err := server.registry.FindMessageByName(protoreflect.FullName(name))
+	if err != nil {
+		t.Fatalf("FindMessageByName(%s): %v", name, err)
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	msg := dynamicpb.NewMessage(mt.Descriptor())
+	if err := protojson.Unmarshal(data, msg); err != nil {
+		t.Fatalf("Unmarshal %s: %v", name, err)
+	}
+
+	return msg
+}
+
+// openDemoStream opens a stream of a demo.Demo method
+func openDemoStream(t *testing.T, conn *grpc.ClientConn, method string, clientStreams, serverStreams bool) grpc.ClientStream {
+	t.Helper()
+
+	desc := &grpc.StreamDesc{StreamName: method, ClientStreams: clientStreams, ServerStreams: serverStreams}
+
+	stream, err := conn.NewStream(testContext(t), desc, "/demo.Demo/"+method)
+	if err != nil {
+		t.Fatalf("NewStream(%s): %v", method, err)
+	}
+
+	return stream
+}
+
+// sendRequest sends a demo.Request with the name on the stream
+func sendRequest(t *testing.T, server *Server, stream grpc.ClientStream, name string) {
+	t.Helper()
+
+	if err := stream.SendMsg(demoMessage(t, server, "demo.Request", map[string]interface{}{"name": name})); err != nil {
+		t.Fatalf("Send %s: %v", name, err)
+	}
+}
+
+// callUnary calls demo.Demo/Unary with the name and returns the response message
+func callUnary(t *testing.T, server *Server, conn *grpc.ClientConn, name string) (string, error) {
+	t.Helper()
+
+	req := demoMessage(t, server, "demo.Request", map[string]interface{}{"name": name})
+	resp := demoMessage(t, server, "demo.Response", map[string]interface{}{})
+	if err := conn.Invoke(testContext(t), "/demo.Demo/Unary", req, resp); err != nil {
+		return "", err
+	}
+
+	return resp.Get(resp.Descriptor().Fields().ByName("message")).String(), nil
+}
+
+// recvMessage receives a demo.Response and returns its message field
+func recvMessage(t *testing.T, server *Server, stream grpc.ClientStream) (string, error) {
+	t.Helper()
+
+	resp := demoMessage(t, server, "demo.Response", map[string]interface{}{})
+	if err := stream.RecvMsg(resp); err != nil {
+		return "", err
+	}
+
+	return resp.Get(resp.Descriptor().Fields().ByName("message")).String(), nil
+}
+
+func TestBidiAlternativeInputs(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := server.On("demo.Demo/Bidi").
+		MatchingAny(
+			stuber.InputData{Equals: map[string]interface{}{"name": "hello"}},
+			stuber.InputData{Equals: map[string]interface{}{"name": "hi"}},
+		).
+		Returns(map[string]interface{}{"message": "greeting"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	stream := openDemoStream(t, conn, "Bidi", true, true)
+	for _, name := range []string{"hi", "hello"} {
+		if err := stream.SendMsg(demoMessage(t, server, "demo.Request", map[string]interface{}{"name": name})); err != nil {
+			t.Fatalf("Send %s: %v", name, err)
+		}
+
+		message, err := recvMessage(t, server, stream)
+		if err != nil {
+			t.Fatalf("Recv for %s: %v", name, err)
+		}
+		if message != "greeting" {
+			t.Fatalf("message for %s = %q, want greeting", name, message)
+		}
+	}
+
+	if err := stream.SendMsg(demoMessage(t, server, "demo.Request", map[string]interface{}{"name": "bye"})); err != nil {
+		t.Fatalf("Send bye: %v", err)
+	}
+
+	_, err = recvMessage(t, server, stream)
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Recv for bye: %v, want NotFound", err)
+	}
+	if !strings.Contains(status.Convert(err).Message(), "equals.name: expected") {
+		t.Fatalf("error %q does not list the stub with inputs as a near miss", status.Convert(err).Message())
+	}
+}
+
+func TestNearMissesLogged(t *testing.T) {
+	var logs bytes.Buffer
+	server, conn := startServer(t, []string{"testdata/demo"}, WithLogger(zerolog.New(&logs)))
+
+	err := server.On("demo.Demo/Unary").
+		Matching(map[string]interface{}{"name": "alice"}).
+		Returns(map[string]interface{}{"message": "hi"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	req := demoMessage(t, server, "demo.Request", map[string]interface{}{"name": "bob"})
+	resp := demoMessage(t, server, "demo.Response", map[string]interface{}{})
+	if err := conn.Invoke(testContext(t), "/demo.Demo/Unary", req, resp); status.Code(err) != codes.NotFound {
+		t.Fatalf("Invoke: %v, want NotFound", err)
+	}
+
+	if !strings.Contains(logs.String(), "Stub did not match") {
+		t.Fatalf("near miss not logged, got %q", logs.String())
+	}
+}
+
+func TestUnary(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := server.On("demo.Demo/Unary").
+		Matching(map[string]interface{}{"name": "alice"}).
+		Returns(map[string]interface{}{"message": "hi alice"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	message, err := callUnary(t, server, conn, "alice")
+	if err != nil {
+		t.Fatalf("Unary: %v", err)
+	}
+	if message != "hi alice" {
+		t.Fatalf("message = %q, want hi alice", message)
+	}
+
+	if _, err := callUnary(t, server, conn, "bob"); status.Code(err) != codes.NotFound {
+		t.Fatalf("Unary for bob: %v, want NotFound", err)
+	}
+}
+
+func TestServerStream(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := server.On("demo.Demo/ServerStream").
+		Matching(map[string]interface{}{"name": "count"}).
+		ReturnsStream(
+			map[string]interface{}{"message": "one"},
+			map[string]interface{}{"message": "two"},
+			map[string]interface{}{"message": "three"},
+		).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	stream := openDemoStream(t, conn, "ServerStream", false, true)
+	sendRequest(t, server, stream, "count")
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+
+	var messages []string
+	for {
+		message, err := recvMessage(t, server, stream)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		messages = append(messages, message)
+	}
+
+	if strings.Join(messages, ",") != "one,two,three" {
+		t.Fatalf("messages = %v, want one, two, three", messages)
+	}
+}
+
+func TestClientStreamInputs(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := server.On("demo.Demo/ClientStream").
+		MatchingSequence(
+			stuber.InputData{Equals: map[string]interface{}{"name": "a"}},
+			stuber.InputData{Equals: map[string]interface{}{"name": "b"}},
+		).
+		Returns(map[string]interface{}{"message": "sequence"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	err = server.On("demo.Demo/ClientStream").
+		Matching(map[string]interface{}{"name": "z"}).
+		Returns(map[string]interface{}{"message": "last"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	for _, tc := range []struct {
+		names []string
+		want  string
+	}{
+		{names: []string{"a", "b"}, want: "sequence"},
+		{names: []string{"x", "z"}, want: "last"},
+		{names: []string{"b", "a"}},
+	} {
+		stream := openDemoStream(t, conn, "ClientStream", true, false)
+		for _, name := range tc.names {
+			sendRequest(t, server, stream, name)
+		}
+		if err := stream.CloseSend(); err != nil {
+			t.Fatalf("CloseSend: %v", err)
+		}
+
+		message, err := recvMessage(t, server, stream)
+		if tc.want == "" {
+			if status.Code(err) != codes.NotFound {
+				t.Fatalf("ClientStream %v: %v, want NotFound", tc.names, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ClientStream %v: %v", tc.names, err)
+		}
+		if message != tc.want {
+			t.Fatalf("message for %v = %q, want %q", tc.names, message, tc.want)
+		}
+	}
+}
+
+func TestBidiPerMessage(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := server.On("demo.Demo/Bidi").
+		Matching(map[string]interface{}{"name": "a"}).
+		Returns(map[string]interface{}{"message": "A"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	err = server.On("demo.Demo/Bidi").
+		Matching(map[string]interface{}{"name": "b"}).
+		ReturnsStream(map[string]interface{}{"message": "B1"}, map[string]interface{}{"message": "B2"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	stream := openDemoStream(t, conn, "Bidi", true, true)
+	for _, step := range []struct {
+		name string
+		want []string
+	}{
+		{name: "a", want: []string{"A"}},
+		{name: "b", want: []string{"B1", "B2"}},
+		{name: "a", want: []string{"A"}},
+	} {
+		sendRequest(t, server, stream, step.name)
+
+		for _, want := range step.want {
+			message, err := recvMessage(t, server, stream)
+			if err != nil {
+				t.Fatalf("Recv for %s: %v", step.name, err)
+			}
+			if message != want {
+				t.Fatalf("message for %s = %q, want %q", step.name, message, want)
+			}
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+	if _, err := recvMessage(t, server, stream); !errors.Is(err, io.EOF) {
+		t.Fatalf("Recv after CloseSend: %v, want EOF", err)
+	}
+}
+
+func TestBidiConversation(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := NewEmbeddedMocker(server).AddStub("demo.Demo", "Bidi", nil, map[string]interface{}{
+		"conversation": []interface{}{
+			map[string]interface{}{
+				"input":  map[string]interface{}{"equals": map[string]interface{}{"name": "hello"}},
+				"output": map[string]interface{}{"message": "hi"},
+			},
+			map[string]interface{}{
+				"input":  map[string]interface{}{"equals": map[string]interface{}{"name": "bye"}},
+				"output": map[string]interface{}{"message": "see you"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("AddStub: %v", err)
+	}
+
+	stream := openDemoStream(t, conn, "Bidi", true, true)
+	for _, step := range []struct{ name, want string }{
+		{name: "hello", want: "hi"},
+		{name: "bye", want: "see you"},
+	} {
+		sendRequest(t, server, stream, step.name)
+
+		message, err := recvMessage(t, server, stream)
+		if err != nil {
+			t.Fatalf("Recv for %s: %v", step.name, err)
+		}
+		if message != step.want {
+			t.Fatalf("message for %s = %q, want %q", step.name, message, step.want)
+		}
+	}
+
+	sendRequest(t, server, stream, "again")
+	if _, err := recvMessage(t, server, stream); status.Code(err) != codes.NotFound {
+		t.Fatalf("Recv past the conversation: %v, want NotFound", err)
+	}
+
+	// A conversation out of order does not match its first step
+	stream = openDemoStream(t, conn, "Bidi", true, true)
+	sendRequest(t, server, stream, "bye")
+	if _, err := recvMessage(t, server, stream); status.Code(err) != codes.NotFound {
+		t.Fatalf("Recv for bye first: %v, want NotFound", err)
+	}
+}
+
+func TestUnaryAlternativeInputs(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := NewEmbeddedMocker(server).AddStub("demo.Demo", "Unary", map[string]interface{}{
+		"inputs": []interface{}{
+			map[string]interface{}{"equals": map[string]interface{}{"name": "alice"}},
+			map[string]interface{}{"equals": map[string]interface{}{"name": "bob"}},
+		},
+	}, map[string]interface{}{"message": "known"})
+	if err != nil {
+		t.Fatalf("AddStub: %v", err)
+	}
+
+	for _, name := range []string{"alice", "bob"} {
+		message, err := callUnary(t, server, conn, name)
+		if err != nil {
+			t.Fatalf("Unary for %s: %v", name, err)
+		}
+		if message != "known" {
+			t.Fatalf("message for %s = %q, want known", name, message)
+		}
+	}
+
+	if _, err := callUnary(t, server, conn, "carol"); status.Code(err) != codes.NotFound {
+		t.Fatalf("Unary for carol: %v, want NotFound", err)
+	}
+}
+
+func TestTimesExhaustion(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := server.On("demo.Demo/Unary").
+		Matching(map[string]interface{}{"name": "alice"}).
+		Returns(map[string]interface{}{"message": "limited"}).
+		Times(2).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	err = server.On("demo.Demo/Bidi").
+		Matching(map[string]interface{}{"name": "alice"}).
+		Returns(map[string]interface{}{"message": "once"}).
+		Times(1).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	for i := range 2 {
+		if _, err := callUnary(t, server, conn, "alice"); err != nil {
+			t.Fatalf("Unary call %d: %v", i+1, err)
+		}
+	}
+	if _, err := callUnary(t, server, conn, "alice"); status.Code(err) != codes.NotFound {
+		t.Fatalf("Unary call 3: %v, want NotFound", err)
+	}
+
+	stream := openDemoStream(t, conn, "Bidi", true, true)
+	sendRequest(t, server, stream, "alice")
+	if _, err := recvMessage(t, server, stream); err != nil {
+		t.Fatalf("Bidi message 1: %v", err)
+	}
+	sendRequest(t, server, stream, "alice")
+	if _, err := recvMessage(t, server, stream); status.Code(err) != codes.NotFound {
+		t.Fatalf("Bidi message 2: %v, want NotFound", err)
+	}
+
+	if calls := server.Calls("demo.Demo", "Unary"); len(calls) != 3 || calls[2].StubID != nil {
+		t.Fatalf("Unary calls = %+v, want 3 with the last unmatched", calls)
+	}
+}
+
+func TestBidiTimesFallback(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := server.On("demo.Demo/Bidi").
+		Matching(map[string]interface{}{"name": "alice"}).
+		Returns(map[string]interface{}{"message": "first"}).
+		Priority(10).
+		Times(1).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	err = server.On("demo.Demo/Bidi").
+		MatchingAny(stuber.InputData{Equals: map[string]interface{}{"name": "alice"}}).
+		Returns(map[string]interface{}{"message": "fallback"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	stream := openDemoStream(t, conn, "Bidi", true, true)
+	for _, want := range []string{"first", "fallback", "fallback"} {
+		sendRequest(t, server, stream, "alice")
+
+		message, err := recvMessage(t, server, stream)
+		if err != nil {
+			t.Fatalf("Recv for %s: %v", want, err)
+		}
+		if message != want {
+			t.Fatalf("message = %q, want %q", message, want)
+		}
+	}
+
+	server.extensions.mu.RLock()
+	defer server.extensions.mu.RUnlock()
+	if len(server.extensions.items) != 1 || len(server.extensions.uses) != 0 {
+		t.Fatalf("extensions of the used up stub kept: %d items, %d use counts", len(server.extensions.items), len(server.extensions.uses))
+	}
+}
+
+func TestBidiStubAddedDuringCall(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/demo"})
+
+	err := server.On("demo.Demo/Bidi").
+		Matching(map[string]interface{}{"name": "a"}).
+		Returns(map[string]interface{}{"message": "A"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	stream := openDemoStream(t, conn, "Bidi", true, true)
+	sendRequest(t, server, stream, "a")
+	if _, err := recvMessage(t, server, stream); err != nil {
+		t.Fatalf("Recv for a: %v", err)
+	}
+
+	err = server.On("demo.Demo/Bidi").
+		Matching(map[string]interface{}{"name": "b"}).
+		Returns(map[string]interface{}{"message": "B"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	sendRequest(t, server, stream, "b")
+	message, err := recvMessage(t, server, stream)
+	if err != nil {
+		t.Fatalf("Recv for b: %v", err)
+	}
+	if message != "B" {
+		t.Fatalf("message for b = %q, want B", message)
+	}
+}
@@ -0,0 +1,221 @@
+package gripmock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // accept gzip-compressed requests
+	"google.golang.org/grpc/keepalive"
+)
+
+// Option configures a Server created by NewServer
+type Option func(*Server)
+
+// WithStrictMode makes Verify also fail on stubs that no call has hit,
+// in addition to calls that found no stub
+func WithStrictMode() Option {
+	return func(s *Server) {
+		s.strict = true
+	}
+}
+
+// WithLogger logs the server's diagnostics, such as how the closest stubs differ
+// from an unmatched request, and the compilation of the protos to the logger.
+// By default nothing is logged.
+func WithLogger(logger zerolog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithAdminPort serves the admin REST API (stubs, services, health) on the given
+// port; port 0 lets the OS pick a free port, reported by GetAdminPort
+func WithAdminPort(port int) Option {
+	return func(s *Server) {
+		s.admin = true
+		s.adminPort = port
+	}
+}
+
+// WithAddress listens on the given address instead of the port passed to NewServer:
+// a TCP host:port such as "127.0.0.1:0", or a Unix domain socket such as
+// "unix:///tmp/gripmock.sock". A stale socket file at the path is removed first,
+// one a running server listens on is kept and fails Start.
+func WithAddress(address string) Option {
+	return func(s *Server) {
+		s.address = address
+	}
+}
+
+// WithInMemory serves over an in-memory bufconn listener instead of a TCP port;
+// connect with Server.ClientConn or grpc.WithContextDialer(Server.Dialer())
+func WithInMemory() Option {
+	return func(s *Server) {
+		s.inMemory = true
+	}
+}
+
+// WithTLS serves TLS with the certificate and key of the given PEM files
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		s.tlsOptions().certFile = certFile
+		s.tlsOptions().keyFile = keyFile
+	}
+}
+
+// WithTLSServerName sets the name ClientTLSConfig and ClientConn verify the server
+// certificate for, by default localhost or else a name of the certificate
+func WithTLSServerName(name string) Option {
+	return func(s *Server) {
+		s.serverName = name
+	}
+}
+
+// WithSelfSignedTLS serves TLS with a certificate for localhost issued by a CA
+// generated for the server; fetch it with Server.CACertificate
+func WithSelfSignedTLS() Option {
+	return func(s *Server) {
+		s.tlsOptions()
+	}
+}
+
+// WithMutualTLS requires clients to present a certificate signed by the CA of
+// the given PEM file, or by the generated CA if the file is empty (see
+// Server.IssueClientCertificate). Enables self-signed TLS unless WithTLS is set.
+func WithMutualTLS(clientCAFile string) Option {
+	return func(s *Server) {
+		s.tlsOptions().clientAuth = true
+		s.tlsOptions().clientCAFile = clientCAFile
+	}
+}
+
+// WithImportPaths resolves proto imports from the given directories as well as
+// from the directories of the proto files, e.g. a vendored googleapis checkout.
+// Like protoc's -I, each path is a root of its own, even inside a proto directory.
+// Services of files only reached through imports are not mocked.
+func WithImportPaths(paths ...string) Option {
+	return func(s *Server) {
+		s.importPaths = append(s.importPaths, paths...)
+	}
+}
+
+// WithDescriptorCache keeps the compiled proto descriptors in dir between test
+// runs and reuses them while the protos and import paths are unchanged. An empty
+// dir uses a gripmock directory in os.UserCacheDir.
+func WithDescriptorCache(dir string) Option {
+	return func(s *Server) {
+		if dir == "" {
+			dir = defaultDescriptorCacheDir()
+		}
+		s.cacheDir = dir
+	}
+}
+
+// defaultDescriptorCacheDir returns the gripmock directory in the user cache
+// directory, or in the temporary directory if the user has none
+func defaultDescriptorCacheDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "gripmock", "descriptors")
+}
+
+// WithServerOptions passes extra options to grpc.NewServer, applied after
+// the ones set by other options so they can override them
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(s *Server) {
+		s.grpcOptions = append(s.grpcOptions, opts...)
+	}
+}
+
+// WithUnaryInterceptors chains interceptors around unary calls, such as auth checks
+// or logging; they run in order, before the stub is matched
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) Option {
+	return func(s *Server) {
+		s.grpcOptions = append(s.grpcOptions, grpc.ChainUnaryInterceptor(interceptors...))
+	}
+}
+
+// WithStreamInterceptors chains interceptors around streaming calls
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) Option {
+	return func(s *Server) {
+		s.grpcOptions = append(s.grpcOptions, grpc.ChainStreamInterceptor(interceptors...))
+	}
+}
+
+// WithMaxRecvMsgSize limits the size in bytes of request messages
+func WithMaxRecvMsgSize(bytes int) Option {
+	return func(s *Server) {
+		s.grpcOptions = append(s.grpcOptions, grpc.MaxRecvMsgSize(bytes))
+	}
+}
+
+// WithMaxSendMsgSize limits the size in bytes of response messages
+func WithMaxSendMsgSize(bytes int) Option {
+	return func(s *Server) {
+		s.grpcOptions = append(s.grpcOptions, grpc.MaxSendMsgSize(bytes))
+	}
+}
+
+// WithKeepalive sets the keepalive parameters of the server and the policy
+// enforced on client pings
+func WithKeepalive(params keepalive.ServerParameters, policy keepalive.EnforcementPolicy) Option {
+	return func(s *Server) {
+		s.grpcOptions = append(s.grpcOptions, grpc.KeepaliveParams(params), grpc.KeepaliveEnforcementPolicy(policy))
+	}
+}
+
+// WithCompressor compresses responses with the registered compressor, e.g. "gzip",
+// for clients that accept it. Compressed requests are accepted regardless.
+func WithCompressor(name string) Option {
+	return func(s *Server) {
+		s.compressor = name
+	}
+}
+
+// tlsOptions returns the TLS settings of the server, enabling TLS
+func (s *Server) tlsOptions() *tlsSettings {
+	if s.tlsSettings == nil {
+		s.tlsSettings = &tlsSettings{}
+	}
+	return s.tlsSettings
+}
+
+// serverOptions returns the options the gRPC server is created with
+func (s *Server) serverOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if s.serverTLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.serverTLS)))
+	}
+
+	if s.compressor != "" {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(compressUnary(s.compressor)),
+			grpc.ChainStreamInterceptor(compressStream(s.compressor)),
+		)
+	}
+
+	return append(opts, s.grpcOptions...)
+}
+
+// compressUnary sets the compressor of unary responses. Clients that do not
+// accept it get uncompressed responses.
+func compressUnary(name string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		_ = grpc.SetSendCompressor(ctx, name)
+		return handler(ctx, req)
+	}
+}
+
+// compressStream sets the compressor of streamed responses
+func compressStream(name string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		_ = grpc.SetSendCompressor(ss.Context(), name)
+		return handler(srv, ss)
+	}
+}
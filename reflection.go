@@ -0,0 +1,25 @@
+package gripmock
+
+import (
+	"google.golang.org/grpc/reflection"
+	v1reflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	v1alphareflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// registerReflection registers the v1 and v1alpha reflection services, so that
+// tools like grpcurl discover the mocked services without a copy of the protos.
+// A reflection service compiled from the protos is stubbed instead.
+func (s *Server) registerReflection() {
+	opts := reflection.ServerOptions{
+		Services:           s.grpcServer,
+		DescriptorResolver: s.registry,
+		ExtensionResolver:  s.registry,
+	}
+
+	if !s.mocked(v1reflectiongrpc.ServerReflection_ServiceDesc.ServiceName) {
+		v1reflectiongrpc.RegisterServerReflectionServer(s.grpcServer, reflection.NewServerV1(opts))
+	}
+	if !s.mocked(v1alphareflectiongrpc.ServerReflection_ServiceDesc.ServiceName) {
+		v1alphareflectiongrpc.RegisterServerReflectionServer(s.grpcServer, reflection.NewServer(opts))
+	}
+}
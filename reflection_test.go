@@ -0,0 +1,84 @@
+package gripmock
+
+import (
+	"testing"
+
+	v1reflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	v1alphareflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+func TestReflectionListsServices(t *testing.T) {
+	_, conn := startServer(t, []string{"testdata/demo"})
+
+	stream, err := v1reflectiongrpc.NewServerReflectionClient(conn).ServerReflectionInfo(testContext(t))
+	if err != nil {
+		t.Fatalf("ServerReflectionInfo: %v", err)
+	}
+	defer stream.CloseSend()
+
+	err = stream.Send(&v1reflectiongrpc.ServerReflectionRequest{
+		MessageRequest: &v1reflectiongrpc.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+
+	found := false
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		found = found || service.GetName() == "demo.Demo"
+	}
+	if !found {
+		t.Fatalf("demo.Demo not listed in %v", resp.GetListServicesResponse().GetService())
+	}
+}
+
+// A vendored reflection.proto is stubbed, while the other reflection
+// version is still served by the built-in service
+func TestMockedReflectionService(t *testing.T) {
+	server, conn := startServer(t, []string{"testdata/reflection"})
+
+	err := server.On("grpc.reflection.v1.ServerReflection/ServerReflectionInfo").
+		ReturnsStream(map[string]interface{}{"valid_host": "stubbed"}).
+		Add()
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	stream, err := v1reflectiongrpc.NewServerReflectionClient(conn).ServerReflectionInfo(testContext(t))
+	if err != nil {
+		t.Fatalf("ServerReflectionInfo: %v", err)
+	}
+	if err := stream.Send(&v1reflectiongrpc.ServerReflectionRequest{Host: "any"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if resp.GetValidHost() != "stubbed" {
+		t.Fatalf("valid host = %q, want the stubbed one", resp.GetValidHost())
+	}
+	stream.CloseSend()
+
+	alpha, err := v1alphareflectiongrpc.NewServerReflectionClient(conn).ServerReflectionInfo(testContext(t))
+	if err != nil {
+		t.Fatalf("v1alpha ServerReflectionInfo: %v", err)
+	}
+	defer alpha.CloseSend()
+
+	err = alpha.Send(&v1alphareflectiongrpc.ServerReflectionRequest{
+		MessageRequest: &v1alphareflectiongrpc.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatalf("v1alpha Send: %v", err)
+	}
+	if _, err := alpha.Recv(); err != nil {
+		t.Fatalf("v1alpha Recv: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package gripmock
+
+import (
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/Dmytro-Hladkykh/gripmock/internal/proto"
+)
+
+// registry holds the descriptors and message types compiled for a single
+// server, so that servers loading different versions of the same proto files
+// do not conflict. Lookups fall back to the global registries for the
+// well-known types and the built-in services.
+type registry struct {
+	files *protoregistry.Files
+	types *dynamicpb.Types
+}
+
+var (
+	_ protodesc.Resolver                  = (*registry)(nil)
+	_ protoregistry.MessageTypeResolver   = (*registry)(nil)
+	_ protoregistry.ExtensionTypeResolver = (*registry)(nil)
+	_ reflection.ExtensionResolver        = (*registry)(nil)
+)
+
+// registries holds the registry built for each set of compiled descriptors,
+// which proto.Build shares between servers loading the same protos
+var registries sync.Map
+
+// registryFor returns the registry of the descriptors, building it once
+func registryFor(descriptors *proto.Descriptors) (*registry, error) {
+	if r, ok := registries.Load(descriptors); ok {
+		return r.(*registry), nil
+	}
+
+	r, err := newRegistry(descriptors)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, _ := registries.LoadOrStore(descriptors, r)
+	return actual.(*registry), nil
+}
+
+// newRegistry builds the files of the descriptors, each after the files it imports
+func newRegistry(descriptors *proto.Descriptors) (*registry, error) {
+	protos := make(map[string]*descriptorpb.FileDescriptorProto)
+	var names []string
+
+	sets := append([]*descriptorpb.FileDescriptorSet{descriptors.Imports}, descriptors.Sets...)
+	for _, set := range sets {
+		for _, file := range set.GetFile() {
+			if _, ok := protos[file.GetName()]; !ok {
+				protos[file.GetName()] = file
+				names = append(names, file.GetName())
+			}
+		}
+	}
+
+	r := &registry{files: new(protoregistry.Files)}
+	for _, name := range names {
+		if err := r.register(name, protos); err != nil {
+			return nil, err
+		}
+	}
+
+	r.types = dynamicpb.NewTypes(r.files)
+
+	return r, nil
+}
+
+// register registers the named file after the files it imports. Files missing
+// from protos are already registered or are resolved from the global registry.
+func (r *registry) register(name string, protos map[string]*descriptorpb.FileDescriptorProto) error {
+	file, ok := protos[name]
+	if !ok {
+		return nil
+	}
+	delete(protos, name)
+
+	for _, dependency := range file.GetDependency() {
+		if err := r.register(dependency, protos); err != nil {
+			return err
+		}
+	}
+
+	fileDesc, err := protodesc.NewFile(file, r)
+	if err != nil {
+		return fmt.Errorf("failed to create file descriptor for %s: %w", name, err)
+	}
+
+	if err := r.files.RegisterFile(fileDesc); err != nil {
+		return fmt.Errorf("failed to register file descriptor for %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (r *registry) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if file, err := r.files.FindFileByPath(path); err == nil {
+		return file, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r *registry) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if desc, err := r.files.FindDescriptorByName(name); err == nil {
+		return desc, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+func (r *registry) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	if mt, err := r.types.FindMessageByName(name); err == nil {
+		return mt, nil
+	}
+	return protoregistry.GlobalTypes.FindMessageByName(name)
+}
+
+func (r *registry) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	if mt, err := r.types.FindMessageByURL(url); err == nil {
+		return mt, nil
+	}
+	return protoregistry.GlobalTypes.FindMessageByURL(url)
+}
+
+func (r *registry) FindExtensionByName(name protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	if xt, err := r.types.FindExtensionByName(name); err == nil {
+		return xt, nil
+	}
+	return protoregistry.GlobalTypes.FindExtensionByName(name)
+}
+
+func (r *registry) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	if xt, err := r.types.FindExtensionByNumber(message, field); err == nil {
+		return xt, nil
+	}
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+// RangeExtensionsByMessage calls f for the extensions of the message in this
+// registry, then for those only in the global registry
+func (r *registry) RangeExtensionsByMessage(message protoreflect.FullName, f func(protoreflect.ExtensionType) bool) {
+	seen := make(map[protoreflect.FieldNumber]bool)
+	done := false
+
+	r.files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		done = !rangeExtensions(file, func(xd protoreflect.ExtensionDescriptor) bool {
+			if xd.ContainingMessage().FullName() != message || seen[xd.Number()] {
+				return true
+			}
+			seen[xd.Number()] = true
+			return f(dynamicpb.NewExtensionType(xd))
+		})
+		return !done
+	})
+	if done {
+		return
+	}
+
+	protoregistry.GlobalTypes.RangeExtensionsByMessage(message, func(xt protoreflect.ExtensionType) bool {
+		if seen[xt.TypeDescriptor().Number()] {
+			return true
+		}
+		return f(xt)
+	})
+}
+
+// extensionContainer is a file or message declaring extensions
+type extensionContainer interface {
+	Extensions() protoreflect.ExtensionDescriptors
+	Messages() protoreflect.MessageDescriptors
+}
+
+// rangeExtensions calls f for the extensions declared in the container and its
+// nested messages, stopping when f returns false
+func rangeExtensions(container extensionContainer, f func(protoreflect.ExtensionDescriptor) bool) bool {
+	extensions := container.Extensions()
+	for i := range extensions.Len() {
+		if !f(extensions.Get(i)) {
+			return false
+		}
+	}
+
+	messages := container.Messages()
+	for i := range messages.Len() {
+		if !rangeExtensions(messages.Get(i), f) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// findMethod returns the descriptor of a method such as "pkg.Service/Method"
+func (r *registry) findMethod(service, method string) (protoreflect.MethodDescriptor, error) {
+	desc, err := r.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("service not found: %s", service)
+	}
+
+	serviceDesc, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("not a service: %s", service)
+	}
+
+	methodDesc := serviceDesc.Methods().ByName(protoreflect.Name(method))
+	if methodDesc == nil {
+		return nil, fmt.Errorf("method not found: %s", methodPath(service, method))
+	}
+
+	return methodDesc, nil
+}
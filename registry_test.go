@@ -0,0 +1,30 @@
+package gripmock
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/Dmytro-Hladkykh/gripmock/internal/proto"
+)
+
+func TestNewRegistryRejectsConflictingFiles(t *testing.T) {
+	file := func(name string) *descriptorpb.FileDescriptorProto {
+		return &descriptorpb.FileDescriptorProto{
+			Name:        lo.ToPtr(name),
+			Package:     lo.ToPtr("conflict"),
+			Syntax:      lo.ToPtr("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{{Name: lo.ToPtr("Message")}},
+		}
+	}
+
+	_, err := newRegistry(&proto.Descriptors{
+		Sets:    []*descriptorpb.FileDescriptorSet{{File: []*descriptorpb.FileDescriptorProto{file("a.proto"), file("b.proto")}}},
+		Imports: &descriptorpb.FileDescriptorSet{},
+	})
+	if err == nil || !strings.Contains(err.Error(), "b.proto") {
+		t.Fatalf("newRegistry = %v, want an error for b.proto", err)
+	}
+}
@@ -0,0 +1,347 @@
+package gripmock
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Pseudo-headers carrying the verified client certificate of mutual TLS calls.
+// Stubs match them like request headers, e.g. Headers.Contains[PeerCommonNameHeader].
+const (
+	// PeerSubjectHeader is the certificate subject, e.g. "CN=billing,O=Acme"
+	PeerSubjectHeader = ":peer-subject"
+	// PeerCommonNameHeader is the common name of the certificate subject
+	PeerCommonNameHeader = ":peer-cn"
+	// PeerSANsHeader lists the DNS, IP, email and URI SANs separated by ";"
+	PeerSANsHeader = ":peer-san"
+)
+
+// certValidity is how long generated certificates are valid
+const certValidity = 24 * time.Hour
+
+// tlsServerName is the name generated server certificates are issued for
+const tlsServerName = "localhost"
+
+// tlsSettings describes how the server serves TLS
+type tlsSettings struct {
+	certFile     string
+	keyFile      string
+	clientAuth   bool
+	clientCAFile string
+	serverName   string
+}
+
+// certificateAuthority is a self-signed CA that issues server and client certificates
+type certificateAuthority struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+// setupTLS builds the server TLS config, generating a CA where the settings
+// leave the server certificate or the client CA unspecified
+func (s *Server) setupTLS() error {
+	settings := s.tlsSettings
+	if settings == nil {
+		return nil
+	}
+
+	if settings.certFile == "" || (settings.clientAuth && settings.clientCAFile == "") {
+		ca, err := newCertificateAuthority()
+		if err != nil {
+			return err
+		}
+		s.ca = ca
+	}
+
+	var cert tls.Certificate
+	var err error
+	if settings.certFile != "" {
+		cert, err = tls.LoadX509KeyPair(settings.certFile, settings.keyFile)
+	} else {
+		cert, err = s.ca.issue(tlsServerName, []string{tlsServerName, "127.0.0.1", "::1"}, x509.ExtKeyUsageServerAuth)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	settings.serverName = s.serverName
+	if settings.serverName == "" {
+		if settings.serverName, err = certificateName(cert); err != nil {
+			return err
+		}
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if settings.clientAuth {
+		pool := x509.NewCertPool()
+		if settings.clientCAFile != "" {
+			if pool, err = loadCertPool(settings.clientCAFile); err != nil {
+				return err
+			}
+		} else {
+			pool.AddCert(s.ca.cert)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	s.serverTLS = config
+	return nil
+}
+
+// CACertificate returns the PEM-encoded certificate of the generated CA,
+// or nil if the server does not use one
+func (s *Server) CACertificate() []byte {
+	if s.ca == nil {
+		return nil
+	}
+	return s.ca.certPEM
+}
+
+// IssueClientCertificate issues a client certificate signed by the generated CA,
+// for servers started with WithMutualTLS without a client CA file. SANs that
+// parse as IPs, emails or URIs are added as such, anything else as a DNS name.
+func (s *Server) IssueClientCertificate(commonName string, sans ...string) (tls.Certificate, error) {
+	if s.ca == nil {
+		return tls.Certificate{}, fmt.Errorf("server has no generated CA")
+	}
+	return s.ca.issue(commonName, sans, x509.ExtKeyUsageClientAuth)
+}
+
+// ClientTLSConfig returns a client TLS config that trusts the server certificate
+// and verifies it for the name of WithTLSServerName or else one of its SANs.
+// Without certificates and with mutual TLS against the generated CA, a client
+// certificate for "gripmock-client" is issued.
+func (s *Server) ClientTLSConfig(certs ...tls.Certificate) (*tls.Config, error) {
+	if s.serverTLS == nil {
+		return nil, fmt.Errorf("server does not use TLS")
+	}
+
+	config := &tls.Config{
+		ServerName:   s.tlsSettings.serverName,
+		Certificates: certs,
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if s.tlsSettings.certFile != "" {
+		pool, err := loadCertPool(s.tlsSettings.certFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	} else {
+		config.RootCAs = x509.NewCertPool()
+		config.RootCAs.AddCert(s.ca.cert)
+	}
+
+	if len(certs) == 0 && s.tlsSettings.clientAuth && s.tlsSettings.clientCAFile == "" {
+		cert, err := s.IssueClientCertificate("gripmock-client")
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// transportCredentials returns the client credentials of ClientConn
+func (s *Server) transportCredentials() (credentials.TransportCredentials, error) {
+	config, err := s.ClientTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// certificateName returns the name clients verify the server certificate for:
+// localhost if the certificate is issued for it, else its first DNS name that
+// is not a wildcard, its first IP address or its common name
+func certificateName(cert tls.Certificate) (string, error) {
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return "", fmt.Errorf("failed to parse server certificate: %w", err)
+		}
+	}
+
+	if leaf.VerifyHostname(tlsServerName) == nil {
+		return tlsServerName, nil
+	}
+	for _, name := range leaf.DNSNames {
+		if !strings.HasPrefix(name, "*.") {
+			return name, nil
+		}
+	}
+	if len(leaf.IPAddresses) > 0 {
+		return leaf.IPAddresses[0].String(), nil
+	}
+	if leaf.Subject.CommonName != "" {
+		return leaf.Subject.CommonName, nil
+	}
+
+	return tlsServerName, nil
+}
+
+func newCertificateAuthority() (*certificateAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	template, err := newCertificateTemplate("gripmock CA")
+	if err != nil {
+		return nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	return &certificateAuthority{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}, nil
+}
+
+// issue creates a certificate signed by the CA
+func (ca *certificateAuthority) issue(commonName string, sans []string, usage x509.ExtKeyUsage) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	template, err := newCertificateTemplate(commonName)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if strings.Contains(san, "://") {
+			uri, err := url.Parse(san)
+			if err != nil {
+				return tls.Certificate{}, fmt.Errorf("invalid URI SAN %q: %w", san, err)
+			}
+			template.URIs = append(template.URIs, uri)
+		} else if strings.Contains(san, "@") {
+			template.EmailAddresses = append(template.EmailAddresses, san)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der, ca.cert.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+func newCertificateTemplate(commonName string) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(certValidity),
+	}, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificates: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+
+	return pool, nil
+}
+
+// peerHeaders returns the pseudo-headers describing the verified client certificate, if any
+func peerHeaders(ctx context.Context) map[string]interface{} {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.PeerCertificates) == 0 {
+		return nil
+	}
+
+	cert := info.State.PeerCertificates[0]
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	headers := map[string]interface{}{
+		PeerSubjectHeader:    cert.Subject.String(),
+		PeerCommonNameHeader: cert.Subject.CommonName,
+	}
+	if len(sans) > 0 {
+		headers[PeerSANsHeader] = strings.Join(sans, ";")
+	}
+
+	return headers
+}
@@ -0,0 +1,79 @@
+package gripmock
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// writeCertificate writes a certificate issued for the names and its key as PEM
+// files, returning their paths
+func writeCertificate(t *testing.T, names ...string) (string, string) {
+	t.Helper()
+
+	ca, err := newCertificateAuthority()
+	if err != nil {
+		t.Fatalf("newCertificateAuthority: %v", err)
+	}
+
+	cert, err := ca.issue(names[0], names, x509.ExtKeyUsageServerAuth)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+
+	var certPEM []byte
+	for _, der := range cert.Certificate {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestClientTLSConfigServerName(t *testing.T) {
+	for _, tc := range []struct {
+		names []string
+		opts  []Option
+		want  string
+	}{
+		{names: []string{"mock.internal", "other.internal"}, want: "mock.internal"},
+		{names: []string{"*.internal", "10.0.0.1"}, want: "10.0.0.1"},
+		{names: []string{"mock.internal", "localhost"}, want: "localhost"},
+		{names: []string{"mock.internal", "other.internal"}, opts: []Option{WithTLSServerName("other.internal")}, want: "other.internal"},
+	} {
+		certFile, keyFile := writeCertificate(t, tc.names...)
+
+		server, conn := startServer(t, []string{"testdata/demo"}, append(tc.opts, WithTLS(certFile, keyFile))...)
+
+		config, err := server.ClientTLSConfig()
+		if err != nil {
+			t.Fatalf("ClientTLSConfig: %v", err)
+		}
+		if config.ServerName != tc.want {
+			t.Fatalf("ServerName for %v = %q, want %q", tc.names, config.ServerName, tc.want)
+		}
+
+		_, err = healthv1.NewHealthClient(conn).Check(testContext(t), &healthv1.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("Check over TLS for %v: %v", tc.names, err)
+		}
+	}
+}
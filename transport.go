@@ -0,0 +1,161 @@
+package gripmock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// bufconnSize is the buffer size of in-memory connections
+const bufconnSize = 1024 * 1024
+
+// staleSocketTimeout bounds the dial that tells a stale socket from a live one
+const staleSocketTimeout = time.Second
+
+// inMemoryTarget is the dial target of in-memory servers, resolved by Dialer
+const inMemoryTarget = "passthrough:///gripmock"
+
+// listen opens the listener the gRPC server is served on
+func (s *Server) listen() (net.Listener, error) {
+	if s.inMemory {
+		return bufconn.Listen(bufconnSize), nil
+	}
+
+	network, address := s.listenAddress()
+	if network == "unix" {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s %s: %w", network, address, err)
+	}
+
+	return listener, nil
+}
+
+// listenAddress returns the network and address to listen on. Addresses
+// prefixed with unix:// or unix: are Unix domain socket paths, any other
+// address is a TCP host:port; without an address the server listens on its port.
+func (s *Server) listenAddress() (string, string) {
+	switch {
+	case s.address == "":
+		return "tcp", fmt.Sprintf(":%d", s.port)
+	case strings.HasPrefix(s.address, "unix://"):
+		return "unix", strings.TrimPrefix(s.address, "unix://")
+	case strings.HasPrefix(s.address, "unix:"):
+		return "unix", strings.TrimPrefix(s.address, "unix:")
+	default:
+		return "tcp", s.address
+	}
+}
+
+// dialAddress returns the network and address clients connect to
+func (s *Server) dialAddress() (string, string) {
+	switch addr := s.Addr().(type) {
+	case *net.UnixAddr:
+		return "unix", addr.Name
+	case *net.TCPAddr:
+		if addr.IP == nil || addr.IP.IsUnspecified() {
+			return "tcp", fmt.Sprintf("localhost:%d", addr.Port)
+		}
+		return "tcp", addr.String()
+	}
+
+	network, address := s.listenAddress()
+	if network == "tcp" {
+		return network, fmt.Sprintf("localhost:%d", s.GetPort())
+	}
+	return network, address
+}
+
+// removeStaleSocket removes a socket file left behind by a server that did not
+// shut down cleanly, so that listening on the path does not fail. A socket
+// still accepting connections belongs to a running server and is kept.
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat socket %s: %w", path, err)
+	}
+
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, staleSocketTimeout)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s: %w", path, syscall.EADDRINUSE)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("failed to check socket %s: %w", path, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Dialer returns a dial function for grpc.WithContextDialer that connects to
+// the in-memory listener of a server started with WithInMemory
+func (s *Server) Dialer() func(context.Context, string) (net.Conn, error) {
+	return func(ctx context.Context, _ string) (net.Conn, error) {
+		s.mu.RLock()
+		listener, ok := s.listener.(*bufconn.Listener)
+		s.mu.RUnlock()
+
+		if !ok || listener == nil {
+			return nil, fmt.Errorf("server is not running in memory")
+		}
+
+		return listener.DialContext(ctx)
+	}
+}
+
+// ClientConn returns a client connection to the server, in memory for servers
+// started with WithInMemory; over TLS it uses the config of ClientTLSConfig
+func (s *Server) ClientConn(opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	network, address := s.dialAddress()
+	target := address
+	if network == "unix" {
+		target = "unix:" + address
+	}
+	creds := insecure.NewCredentials()
+	if s.serverTLS != nil {
+		tlsCreds, err := s.transportCredentials()
+		if err != nil {
+			return nil, err
+		}
+		creds = tlsCreds
+	}
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+
+	if s.inMemory {
+		target = inMemoryTarget
+		dialOpts = append(dialOpts, grpc.WithContextDialer(s.Dialer()))
+	}
+
+	conn, err := grpc.NewClient(target, append(dialOpts, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client connection: %w", err)
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,45 @@
+package gripmock
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestRemoveStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stale.sock")
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+
+	if err := removeStaleSocket(path); err != nil {
+		t.Fatalf("removeStaleSocket: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("stale socket not removed: %v", err)
+	}
+}
+
+func TestRemoveStaleSocketKeepsLiveSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "live.sock")
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+
+	if err := removeStaleSocket(path); !errors.Is(err, syscall.EADDRINUSE) {
+		t.Fatalf("removeStaleSocket = %v, want address in use", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("live socket removed: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package gripmock
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/gripmock/stuber"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// AddTypedStub adds a stub to all servers answering requests equal to req with resp.
+// The method is the one taking Req and returning Resp; use AddMethodStub when
+// several methods share these types. A nil req matches any request.
+func AddTypedStub[Req, Resp proto.Message](req Req, resp Resp) error {
+	return AddMethodStub("", req, resp)
+}
+
+// AddMethodStub adds a stub to all servers for a method given as its generated
+// full method name, e.g. helloworld.Greeter_SayHello_FullMethodName
+func AddMethodStub[Req, Resp proto.Message](fullMethod string, req Req, resp Resp) error {
+	if globalManager == nil {
+		return fmt.Errorf("embedded gripmock not initialized - call InitEmbeddedGripmock first")
+	}
+	return globalManager.addMessageStub(fullMethod, req, resp)
+}
+
+// AddTypedStubTo adds a typed stub to a single server, see AddTypedStub
+func AddTypedStubTo[Req, Resp proto.Message](m *EmbeddedMocker, req Req, resp Resp) error {
+	return m.addMessageStub("", req, resp)
+}
+
+// AddMethodStubTo adds a typed stub for a method to a single server, see AddMethodStub
+func AddMethodStubTo[Req, Resp proto.Message](m *EmbeddedMocker, fullMethod string, req Req, resp Resp) error {
+	return m.addMessageStub(fullMethod, req, resp)
+}
+
+// addMessageStub adds a stub built from generated messages to all servers
+func (m *MultiServerManager) addMessageStub(fullMethod string, req, resp proto.Message) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var lastErr error
+	for _, mocker := range m.servers {
+		if err := mocker.addMessageStub(fullMethod, req, resp); err != nil {
+			lastErr = fmt.Errorf("failed to add stub to server on %s: %w", mocker.GetServer().Addr(), err)
+		}
+	}
+
+	return lastErr
+}
+
+// addMessageStub adds a stub built from generated messages, resolving the
+// method from fullMethod or, if empty, from the message types
+func (m *EmbeddedMocker) addMessageStub(fullMethod string, req, resp proto.Message) error {
+	service, method, err := m.server.resolveMethod(fullMethod, req.ProtoReflect().Descriptor(), resp.ProtoReflect().Descriptor())
+	if err != nil {
+		return err
+	}
+
+	data, err := messageData(resp)
+	if err != nil {
+		return err
+	}
+
+	stub := &stuber.Stub{
+		Service: service,
+		Method:  method,
+		Input:   messageInput(req),
+		Output:  stuber.Output{Data: data},
+	}
+
+	return m.server.AddStub(stub)
+}
+
+// messageInput matches requests equal to the message, converted the way the
+// server converts incoming requests
+func messageInput(req proto.Message) stuber.InputData {
+	if !req.ProtoReflect().IsValid() {
+		return stuber.InputData{Matches: map[string]interface{}{}}
+	}
+
+	return stuber.InputData{Equals: new(SimpleMocker).convertToMap(req)}
+}
+
+// messageData converts a response message into stub output data
+func messageData(resp proto.Message) (map[string]interface{}, error) {
+	if !resp.ProtoReflect().IsValid() {
+		return map[string]interface{}{}, nil
+	}
+
+	jsonData, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response: %w", err)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return nil, fmt.Errorf("failed to convert response: %w", err)
+	}
+
+	return data, nil
+}
+
+// resolveMethod returns the service and method of a full method name such as
+// "/pkg.Service/Method", or of the only compiled method with the given
+// request and response types, checking that the types match the method
+func (s *Server) resolveMethod(fullMethod string, input, output protoreflect.MessageDescriptor) (string, string, error) {
+	if fullMethod != "" {
+		service, method, err := splitMethod(fullMethod)
+		if err != nil {
+			return "", "", err
+		}
+
+		desc, err := s.registry.findMethod(service, method)
+		if err != nil {
+			return "", "", err
+		}
+
+		if desc.Input().FullName() != input.FullName() || desc.Output().FullName() != output.FullName() {
+			return "", "", fmt.Errorf("%s takes %s and returns %s, not %s and %s",
+				methodPath(service, method), desc.Input().FullName(), desc.Output().FullName(), input.FullName(), output.FullName())
+		}
+
+		return service, method, nil
+	}
+
+	// Walk the compiled files rather than the registered services, so that
+	// stubs can be added before the server is started
+	var candidates []string
+	for _, set := range s.descriptors.Sets {
+		for _, file := range set.GetFile() {
+			fileDesc, err := s.registry.FindFileByPath(file.GetName())
+			if err != nil {
+				continue
+			}
+
+			services := fileDesc.Services()
+			for i := range services.Len() {
+				methods := services.Get(i).Methods()
+				for j := range methods.Len() {
+					desc := methods.Get(j)
+					if desc.Input().FullName() == input.FullName() && desc.Output().FullName() == output.FullName() {
+						candidates = append(candidates, methodPath(string(services.Get(i).FullName()), string(desc.Name())))
+					}
+				}
+			}
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", "", fmt.Errorf("no method takes %s and returns %s", input.FullName(), output.FullName())
+	case 1:
+		service, method, _ := strings.Cut(candidates[0], "/")
+		return service, method, nil
+	default:
+		return "", "", fmt.Errorf("several methods take %s and return %s, use a full method name: %s",
+			input.FullName(), output.FullName(), strings.Join(candidates, ", "))
+	}
+}
+
+// splitMethod splits a method name such as "/pkg.Service/Method" or
+// "pkg.Service/Method" into its service and method
+func splitMethod(fullMethod string) (string, string, error) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok || service == "" || method == "" {
+		return "", "", fmt.Errorf("invalid full method name %q", fullMethod)
+	}
+
+	return service, method, nil
+}
@@ -0,0 +1,64 @@
+package gripmock
+
+import (
+	"strings"
+	"testing"
+
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	v1reflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// newStoppedMocker creates a server for the protos without starting it
+func newStoppedMocker(t *testing.T, protoFiles []string) *EmbeddedMocker {
+	t.Helper()
+
+	server, err := NewServer(0, protoFiles, WithInMemory())
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	return NewEmbeddedMocker(server)
+}
+
+func TestTypedStubBeforeStart(t *testing.T) {
+	mocker := newStoppedMocker(t, []string{"testdata/reflection"})
+
+	err := AddTypedStubTo(mocker, &v1reflectiongrpc.ServerReflectionRequest{Host: "a"}, &v1reflectiongrpc.ServerReflectionResponse{ValidHost: "b"})
+	if err != nil {
+		t.Fatalf("AddTypedStubTo: %v", err)
+	}
+
+	stubs := mocker.GetServer().budgerigar.All()
+	if len(stubs) != 1 || stubs[0].Service != "grpc.reflection.v1.ServerReflection" || stubs[0].Method != "ServerReflectionInfo" {
+		t.Fatalf("stubs = %+v, want one for ServerReflectionInfo", stubs)
+	}
+}
+
+func TestTypedStubAmbiguousMethod(t *testing.T) {
+	mocker := newStoppedMocker(t, []string{"testdata/health"})
+
+	err := AddTypedStubTo(mocker, &healthv1.HealthCheckRequest{}, &healthv1.HealthCheckResponse{})
+	if err == nil || !strings.Contains(err.Error(), "grpc.health.v1.Health/Check, grpc.health.v1.Health/Watch") {
+		t.Fatalf("AddTypedStubTo = %v, want an error naming both methods", err)
+	}
+
+	err = AddMethodStubTo(mocker, healthv1.Health_Check_FullMethodName, &healthv1.HealthCheckRequest{}, &healthv1.HealthCheckResponse{})
+	if err != nil {
+		t.Fatalf("AddMethodStubTo: %v", err)
+	}
+}
+
+func TestTypedStubTypeMismatch(t *testing.T) {
+	mocker := newStoppedMocker(t, []string{"testdata/health"})
+
+	err := AddMethodStubTo(mocker, healthv1.Health_Check_FullMethodName, &healthv1.HealthCheckRequest{}, &healthv1.HealthCheckRequest{})
+	if err == nil || !strings.Contains(err.Error(), "takes grpc.health.v1.HealthCheckRequest and returns grpc.health.v1.HealthCheckResponse") {
+		t.Fatalf("AddMethodStubTo = %v, want a type mismatch error", err)
+	}
+
+	err = AddTypedStubTo(mocker, &emptypb.Empty{}, &emptypb.Empty{})
+	if err == nil || !strings.Contains(err.Error(), "no method takes google.protobuf.Empty") {
+		t.Fatalf("AddTypedStubTo = %v, want no method error", err)
+	}
+}